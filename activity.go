@@ -0,0 +1,115 @@
+package pgsp
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Activity is the session of the backend that runs the progress (pg_stat_activity).
+type Activity struct {
+	Usename         sql.NullString `db:"usename"`
+	ApplicationName sql.NullString `db:"application_name"`
+	ClientAddr      sql.NullString `db:"client_addr"`
+	BackendType     sql.NullString `db:"backend_type"`
+	Query           sql.NullString `db:"query"`
+	QueryStart      sql.NullTime   `db:"query_start"`
+	WaitEventType   sql.NullString `db:"wait_event_type"`
+	WaitEvent       sql.NullString `db:"wait_event"`
+	State           sql.NullString `db:"state"`
+}
+
+// activityColumns is the columns of pg_stat_activity joined to the progress views.
+var activityColumns = []string{
+	"a.usename",
+	"a.application_name",
+	"a.client_addr::text AS client_addr",
+	"a.backend_type",
+	"a.query",
+	"a.query_start",
+	"a.wait_event_type",
+	"a.wait_event",
+	"a.state",
+}
+
+// sessionColumns is the columns of Activity.
+var sessionColumns = []string{
+	"usename",
+	"application_name",
+	"client_addr",
+	"backend_type",
+	"query",
+	"query_start",
+	"wait_event_type",
+	"wait_event",
+	"state",
+}
+
+// field returns the pointer to the field of the column of the session.
+func (a *Activity) field(column string) interface{} {
+	switch column {
+	case "usename":
+		return &a.Usename
+	case "application_name":
+		return &a.ApplicationName
+	case "client_addr":
+		return &a.ClientAddr
+	case "backend_type":
+		return &a.BackendType
+	case "query":
+		return &a.Query
+	case "query_start":
+		return &a.QueryStart
+	case "wait_event_type":
+		return &a.WaitEventType
+	case "wait_event":
+		return &a.WaitEvent
+	case "state":
+		return &a.State
+	}
+	return nil
+}
+
+func (a Activity) Session() Activity {
+	return a
+}
+
+// Autovacuum returns true if the backend is an autovacuum worker.
+func (a Activity) Autovacuum() bool {
+	return a.BackendType.String == "autovacuum worker"
+}
+
+// Wait returns the wait event as "type:event".
+func (a Activity) Wait() string {
+	if !a.WaitEventType.Valid {
+		return ""
+	}
+	return a.WaitEventType.String + ":" + a.WaitEvent.String
+}
+
+// Summary returns a single line description of the session.
+func (a Activity) Summary() string {
+	var s []string
+	if a.BackendType.Valid {
+		s = append(s, a.BackendType.String)
+	}
+	if a.Usename.Valid {
+		s = append(s, "user="+a.Usename.String)
+	}
+	if a.ApplicationName.String != "" {
+		s = append(s, "app="+a.ApplicationName.String)
+	}
+	if a.ClientAddr.Valid {
+		s = append(s, "client="+a.ClientAddr.String)
+	}
+	if a.State.Valid {
+		s = append(s, "state="+a.State.String)
+	}
+	if w := a.Wait(); w != "" {
+		s = append(s, "wait="+w)
+	}
+	if a.QueryStart.Valid {
+		s = append(s, "since="+a.QueryStart.Time.Format(time.RFC3339))
+	}
+	return strings.Join(s, " ")
+}
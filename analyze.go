@@ -1,15 +1,10 @@
 package pgsp
 
 import (
-	"bytes"
 	"context"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	"github.com/noborus/pgsp/str"
-	"github.com/noborus/pgsp/vertical"
-
-	"github.com/olekukonko/tablewriter"
 )
 
 // pg_stat_progress_analyze.
@@ -26,41 +21,26 @@ type Analyze struct {
 	ChildTablesTotal       int64  `db:"child_tables_total"`
 	ChildTablesDone        int64  `db:"child_tables_done"`
 	CurrentChildTableRelid int    `db:"current_child_table_relid"`
+	// Resolved names of the OIDs.
+	RelName               string `db:"-"`
+	CurrentChildTableName string `db:"-"`
+	// Session of the backend.
+	Activity
+	// Columns selected for the server version.
+	viewColumns
 }
 
-var (
-	AnalyzeTableName = "pg_stat_progress_analyze"
-	AnalyzeQuery     string
-	AnalyzeColumns   []string
-)
+var AnalyzeTableName = "pg_stat_progress_analyze"
 
-func GetAnalyze(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
-	if len(AnalyzeColumns) == 0 {
-		AnalyzeColumns = getColumns(Analyze{})
-	}
-	if AnalyzeQuery == "" {
-		AnalyzeQuery = buildQuery(AnalyzeTableName, AnalyzeColumns)
-	}
-	return selectAnalyze(ctx, db, AnalyzeQuery)
+// AnalyzeVersionColumns is the columns of pg_stat_progress_analyze for each server version.
+var AnalyzeVersionColumns = VersionColumns{
+	130000: getColumns(Analyze{}),
 }
 
-func selectAnalyze(ctx context.Context, db *sqlx.DB, query string) ([]Progress, error) {
-	rows, err := db.QueryxContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var as []Progress
-	for rows.Next() {
-		var row Analyze
-		err = rows.StructScan(&row)
-		if err != nil {
-			return nil, err
-		}
-		as = append(as, row)
-	}
-	return as, rows.Err()
+// GetAnalyze returns the rows of pg_stat_progress_analyze with the columns of the latest server version.
+// Pgsp.Get selects the columns of the connected server.
+func GetAnalyze(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
+	return getRows[Analyze](ctx, db, AnalyzeTableName, AnalyzeVersionColumns)
 }
 
 func (v Analyze) Name() string {
@@ -71,42 +51,114 @@ func (v Analyze) Pid() int {
 	return v.PID
 }
 
+func (v Analyze) Relation() string {
+	return relationName(v.RelName, v.RELID)
+}
+
+func (v Analyze) RelationID() (int, int) {
+	return v.DATID, v.RELID
+}
+
+func (v Analyze) Resolve(ctx context.Context, r *Resolver) Progress {
+	v.RelName = r.Name(ctx, v.DATID, v.RELID)
+	v.CurrentChildTableName = r.Name(ctx, v.DATID, v.CurrentChildTableRelid)
+	return v
+}
+
 func (v Analyze) Color() (string, string) {
 	return "#FF7CCB", "#FDFF8C"
 }
 
 func (v Analyze) Table() string {
-	value := str.ToStrStruct(v)
-	buff := new(bytes.Buffer)
+	return renderTable(&v, 7)
+}
 
-	t := tablewriter.NewWriter(buff)
-	t.SetHeader(AnalyzeColumns[0:7])
-	t.Append(value[0:7])
-	t.Render()
+func (v Analyze) Vertical() string {
+	return renderVertical(&v)
+}
 
-	t2 := tablewriter.NewWriter(buff)
-	t2.SetHeader(AnalyzeColumns[7:])
-	t2.Append(value[7:])
-	t2.Render()
+// selectedColumns returns the columns of the view selected for the server version.
+func (v Analyze) selectedColumns() []string {
+	return v.selected(AnalyzeVersionColumns)
+}
 
-	return buff.String()
+// names returns the resolved names of the OID columns.
+func (v Analyze) names() map[string]string {
+	return map[string]string{
+		"relid":                     v.RelName,
+		"current_child_table_relid": v.CurrentChildTableName,
+	}
 }
 
-func (v Analyze) Vertical() string {
-	buff := new(bytes.Buffer)
-	vt := vertical.NewWriter(buff)
-	vt.SetHeader(AnalyzeColumns)
-	vt.AppendStruct(v)
-	vt.Render()
-	return buff.String()
+// field returns the pointer to the field of the column.
+func (v *Analyze) field(column string) interface{} {
+	switch column {
+	case "pid":
+		return &v.PID
+	case "datid":
+		return &v.DATID
+	case "datname":
+		return &v.DATNAME
+	case "relid":
+		return &v.RELID
+	case "phase":
+		return &v.PHASE
+	case "sample_blks_total":
+		return &v.SampleBLKSTotal
+	case "sample_blks_scanned":
+		return &v.SampleBLKSScanned
+	case "ext_stats_total":
+		return &v.ExtStatsTotal
+	case "ext_stats_computed":
+		return &v.ExtStatsComputed
+	case "child_tables_total":
+		return &v.ChildTablesTotal
+	case "child_tables_done":
+		return &v.ChildTablesDone
+	case "current_child_table_relid":
+		return &v.CurrentChildTableRelid
+	}
+	return v.Activity.field(column)
 }
 
-func (v Analyze) Progress() float64 {
-	if v.ChildTablesTotal != 0 {
-		return float64(v.ChildTablesDone) / float64(v.ChildTablesTotal)
+// row returns the selected columns and the session by the column names.
+func (v Analyze) row() map[string]interface{} {
+	return rowOf(&v, v.selectedColumns())
+}
+
+// withColumns returns the row with the columns selected for a server version.
+func (v Analyze) withColumns(columns []string) Progress {
+	v.columns = knownColumns(&v, columns)
+	return v
+}
+
+func (v Analyze) Phase() string {
+	return v.PHASE
+}
+
+func (v Analyze) Counter() Counter {
+	switch v.PHASE {
+	case "acquiring sample rows":
+		return Counter{Done: v.SampleBLKSScanned, Total: v.SampleBLKSTotal, Unit: UnitBlocks}
+	case "acquiring inherited sample rows":
+		return Counter{Done: v.ChildTablesDone, Total: v.ChildTablesTotal, Unit: UnitTables}
+	case "computing extended statistics":
+		return Counter{Done: v.ExtStatsComputed, Total: v.ExtStatsTotal, Unit: UnitStats}
 	}
-	if v.ExtStatsTotal != 0 {
-		return float64(v.ExtStatsComputed) / float64(v.ExtStatsTotal)
+	return Counter{}
+}
+
+func (v Analyze) Status() Status {
+	s := AnalyzePhases.Status(v.PHASE, v.Counter())
+	if v.PHASE == "acquiring inherited sample rows" && v.ChildTablesTotal != 0 {
+		// Add the sample blocks of the current child table.
+		child := Counter{Done: v.SampleBLKSScanned, Total: v.SampleBLKSTotal}
+		s.PhaseProgress = (float64(v.ChildTablesDone) + child.Progress()) / float64(v.ChildTablesTotal)
+		s.Overall = AnalyzePhases.overall(s.Index, s.PhaseProgress)
 	}
-	return float64(v.SampleBLKSScanned) / float64(v.SampleBLKSTotal)
+	return s
+}
+
+func (v Analyze) Progress() float64 {
+	return v.Status().Overall
 }
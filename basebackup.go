@@ -1,15 +1,11 @@
 package pgsp
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	"github.com/noborus/pgsp/str"
-	"github.com/noborus/pgsp/vertical"
-	"github.com/olekukonko/tablewriter"
 )
 
 // pg_stat_progress_basebackup.
@@ -20,41 +16,23 @@ type BaseBackup struct {
 	BackupStreamed      int64         `db:"backup_streamed"`
 	TablespacesTotal    int64         `db:"tablespaces_total"`
 	TablespacesStreamed int64         `db:"tablespaces_streamed"`
+	// Session of the backend.
+	Activity
+	// Columns selected for the server version.
+	viewColumns
 }
 
-var (
-	BaseBackupTableName = "pg_stat_progress_basebackup"
-	BaseBackupQuery     string
-	BaseBackupColumns   []string
-)
+var BaseBackupTableName = "pg_stat_progress_basebackup"
 
-func GetBaseBackup(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
-	if len(BaseBackupColumns) == 0 {
-		BaseBackupColumns = getColumns(BaseBackup{})
-	}
-	if BaseBackupQuery == "" {
-		BaseBackupQuery = buildQuery(BaseBackupTableName, BaseBackupColumns)
-	}
-	return selectBaseBackup(ctx, db, BaseBackupQuery)
+// BaseBackupVersionColumns is the columns of pg_stat_progress_basebackup for each server version.
+var BaseBackupVersionColumns = VersionColumns{
+	130000: getColumns(BaseBackup{}),
 }
 
-func selectBaseBackup(ctx context.Context, db *sqlx.DB, query string) ([]Progress, error) {
-	rows, err := db.QueryxContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var as []Progress
-	for rows.Next() {
-		var row BaseBackup
-		err = rows.StructScan(&row)
-		if err != nil {
-			return nil, err
-		}
-		as = append(as, row)
-	}
-	return as, rows.Err()
+// GetBaseBackup returns the rows of pg_stat_progress_basebackup with the columns of the latest server version.
+// Pgsp.Get selects the columns of the connected server.
+func GetBaseBackup(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
+	return getRows[BaseBackup](ctx, db, BaseBackupTableName, BaseBackupVersionColumns)
 }
 
 func (v BaseBackup) Name() string {
@@ -65,34 +43,82 @@ func (v BaseBackup) Pid() int {
 	return v.PID
 }
 
+func (v BaseBackup) Relation() string {
+	return ""
+}
+
 func (v BaseBackup) Color() (string, string) {
 	return "#FDFF8C", "#FF7CCB"
 }
 
 func (v BaseBackup) Table() string {
-	buff := new(bytes.Buffer)
-	t := tablewriter.NewWriter(buff)
-	t.SetHeader(BaseBackupColumns)
-	t.Append(str.ToStrStruct(v))
-	t.Render()
-
-	return buff.String()
+	return renderTable(&v, 0)
 }
 
 func (v BaseBackup) Vertical() string {
-	buff := new(bytes.Buffer)
-	vt := vertical.NewWriter(buff)
-	vt.SetHeader(BaseBackupColumns)
-	vt.AppendStruct(v)
-	vt.Render()
+	return renderVertical(&v)
+}
 
-	return buff.String()
+// selectedColumns returns the columns of the view selected for the server version.
+func (v BaseBackup) selectedColumns() []string {
+	return v.selected(BaseBackupVersionColumns)
 }
 
-func (v BaseBackup) Progress() float64 {
-	total := v.BackupTotal.Int64
-	if total != 0 {
-		return float64(v.BackupStreamed) / float64(total)
+// names returns nil because the view has no OID of relations.
+func (v BaseBackup) names() map[string]string {
+	return nil
+}
+
+// field returns the pointer to the field of the column.
+func (v *BaseBackup) field(column string) interface{} {
+	switch column {
+	case "pid":
+		return &v.PID
+	case "phase":
+		return &v.PHASE
+	case "backup_total":
+		return &v.BackupTotal
+	case "backup_streamed":
+		return &v.BackupStreamed
+	case "tablespaces_total":
+		return &v.TablespacesTotal
+	case "tablespaces_streamed":
+		return &v.TablespacesStreamed
 	}
-	return float64(v.TablespacesStreamed) / float64(v.TablespacesTotal)
+	return v.Activity.field(column)
+}
+
+// row returns the selected columns and the session by the column names.
+func (v BaseBackup) row() map[string]interface{} {
+	return rowOf(&v, v.selectedColumns())
+}
+
+// withColumns returns the row with the columns selected for a server version.
+func (v BaseBackup) withColumns(columns []string) Progress {
+	v.columns = knownColumns(&v, columns)
+	return v
+}
+
+func (v BaseBackup) Phase() string {
+	return v.PHASE
+}
+
+func (v BaseBackup) Counter() Counter {
+	if v.PHASE != "streaming database files" {
+		return Counter{}
+	}
+	// backup_total is NULL if the estimation is disabled.
+	return Counter{Done: v.BackupStreamed, Total: v.BackupTotal.Int64, Unit: UnitBytes}
+}
+
+func (v BaseBackup) Status() Status {
+	s := BaseBackupPhases.Status(v.PHASE, v.Counter())
+	if v.PHASE == "streaming database files" {
+		s.Extra = []Counter{{Done: v.TablespacesStreamed, Total: v.TablespacesTotal, Unit: UnitTablespaces}}
+	}
+	return s
+}
+
+func (v BaseBackup) Progress() float64 {
+	return v.Status().Overall
 }
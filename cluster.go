@@ -1,15 +1,10 @@
 package pgsp
 
 import (
-	"bytes"
 	"context"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	"github.com/noborus/pgsp/str"
-	"github.com/noborus/pgsp/vertical"
-
-	"github.com/olekukonko/tablewriter"
 )
 
 // pg_stat_progress_Cluster.
@@ -26,41 +21,28 @@ type Cluster struct {
 	HeapBlksTotal     int64  `db:"heap_blks_total"`
 	HeapBlksScanned   int64  `db:"heap_blks_scanned"`
 	IndexRebuildCount int64  `db:"index_rebuild_count"`
+	// Resolved names of the OIDs.
+	RelName          string `db:"-"`
+	ClusterIndexName string `db:"-"`
+	// Filenode is the relfilenode of the relation before it is rewritten, or 0 if unknown.
+	Filenode int64 `db:"-"`
+	// Session of the backend.
+	Activity
+	// Columns selected for the server version.
+	viewColumns
 }
 
-var (
-	ClusterTableName = "pg_stat_progress_cluster"
-	ClusterQuery     string
-	ClusterColumns   []string
-)
+var ClusterTableName = "pg_stat_progress_cluster"
 
-func GetCluster(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
-	if len(ClusterColumns) == 0 {
-		ClusterColumns = getColumns(Cluster{})
-	}
-	if ClusterQuery == "" {
-		ClusterQuery = buildQuery(ClusterTableName, ClusterColumns)
-	}
-	return selectCluster(ctx, db, ClusterQuery)
+// ClusterVersionColumns is the columns of pg_stat_progress_cluster for each server version.
+var ClusterVersionColumns = VersionColumns{
+	120000: getColumns(Cluster{}),
 }
 
-func selectCluster(ctx context.Context, db *sqlx.DB, query string) ([]Progress, error) {
-	rows, err := db.QueryxContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var as []Progress
-	for rows.Next() {
-		var row Cluster
-		err = rows.StructScan(&row)
-		if err != nil {
-			return nil, err
-		}
-		as = append(as, row)
-	}
-	return as, rows.Err()
+// GetCluster returns the rows of pg_stat_progress_cluster with the columns of the latest server version.
+// Pgsp.Get selects the columns of the connected server.
+func GetCluster(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
+	return getRows[Cluster](ctx, db, ClusterTableName, ClusterVersionColumns)
 }
 
 func (v Cluster) Name() string {
@@ -71,37 +53,133 @@ func (v Cluster) Pid() int {
 	return v.PID
 }
 
+func (v Cluster) Relation() string {
+	return relationName(v.RelName, v.RELID)
+}
+
+func (v Cluster) RelationID() (int, int) {
+	return v.DATID, v.RELID
+}
+
+func (v Cluster) Resolve(ctx context.Context, r *Resolver) Progress {
+	v.RelName = r.Name(ctx, v.DATID, v.RELID)
+	v.ClusterIndexName = r.Name(ctx, v.DATID, int(v.ClusterIndexRelid))
+	v.Filenode = r.Filenode(ctx, v.DATID, v.RELID, v.PID, v.QueryStart.Time, v.rewritten())
+	return v
+}
+
 func (v Cluster) Color() (string, string) {
 	return "#5A56E0", "#EE6FF8"
 }
 
 func (v Cluster) Table() string {
-	value := str.ToStrStruct(v)
-	buff := new(bytes.Buffer)
+	return renderTable(&v, 7)
+}
+
+func (v Cluster) Vertical() string {
+	return renderVertical(&v)
+}
+
+// selectedColumns returns the columns of the view selected for the server version.
+func (v Cluster) selectedColumns() []string {
+	return v.selected(ClusterVersionColumns)
+}
 
-	t := tablewriter.NewWriter(buff)
-	t.SetHeader(ClusterColumns[0:7])
-	t.Append(value[0:7])
-	t.Render()
+// names returns the resolved names of the OID columns.
+func (v Cluster) names() map[string]string {
+	return map[string]string{
+		"relid":               v.RelName,
+		"cluster_index_relid": v.ClusterIndexName,
+	}
+}
+
+// field returns the pointer to the field of the column.
+func (v *Cluster) field(column string) interface{} {
+	switch column {
+	case "pid":
+		return &v.PID
+	case "datid":
+		return &v.DATID
+	case "datname":
+		return &v.DATNAME
+	case "relid":
+		return &v.RELID
+	case "command":
+		return &v.Command
+	case "phase":
+		return &v.PHASE
+	case "cluster_index_relid":
+		return &v.ClusterIndexRelid
+	case "heap_tuples_scanned":
+		return &v.HeapTuplesScanned
+	case "heap_tuples_written":
+		return &v.HeapTuplesWritten
+	case "heap_blks_total":
+		return &v.HeapBlksTotal
+	case "heap_blks_scanned":
+		return &v.HeapBlksScanned
+	case "index_rebuild_count":
+		return &v.IndexRebuildCount
+	}
+	return v.Activity.field(column)
+}
 
-	t2 := tablewriter.NewWriter(buff)
-	t2.SetHeader(ClusterColumns[7:])
-	t2.Append(value[7:])
-	t2.Render()
+// row returns the selected columns and the session by the column names.
+func (v Cluster) row() map[string]interface{} {
+	return rowOf(&v, v.selectedColumns())
+}
 
-	return buff.String()
+// withColumns returns the row with the columns selected for a server version.
+func (v Cluster) withColumns(columns []string) Progress {
+	v.columns = knownColumns(&v, columns)
+	return v
 }
 
-func (v Cluster) Vertical() string {
-	buff := new(bytes.Buffer)
-	vt := vertical.NewWriter(buff)
-	vt.SetHeader(ClusterColumns)
-	vt.AppendStruct(v)
-	vt.Render()
+func (v Cluster) Phase() string {
+	return v.PHASE
+}
 
-	return buff.String()
+func (v Cluster) Counter() Counter {
+	switch v.PHASE {
+	case "seq scanning heap":
+		return Counter{Done: v.HeapBlksScanned, Total: v.HeapBlksTotal, Unit: UnitBlocks}
+	case "writing new heap":
+		return Counter{Done: v.HeapTuplesWritten, Total: v.HeapTuplesScanned, Unit: UnitTuples}
+	case "index scanning heap":
+		return Counter{Done: v.HeapTuplesScanned, Unit: UnitTuples}
+	case "rebuilding index":
+		return Counter{Done: v.IndexRebuildCount, Unit: UnitIndexes}
+	}
+	return Counter{}
+}
+
+func (v Cluster) Status() Status {
+	phases := ClusterPhases
+	if v.indexScan() {
+		phases = ClusterIndexScanPhases
+	}
+	return phases.Status(v.PHASE, v.Counter())
+}
+
+// rewritten returns true if the relation may have been swapped with the new heap.
+func (v Cluster) rewritten() bool {
+	phases := ClusterPhases
+	if v.indexScan() {
+		phases = ClusterIndexScanPhases
+	}
+	i := phases.Index(v.PHASE)
+	return i < 0 || i >= phases.Index("swapping relation files")
+}
+
+// indexScan returns true if CLUSTER scans the heap with the index.
+// heap_blks_total is only reported by the sequential scan.
+func (v Cluster) indexScan() bool {
+	if v.PHASE == "index scanning heap" {
+		return true
+	}
+	return v.ClusterIndexRelid != 0 && v.HeapBlksTotal == 0 && v.PHASE != "initializing"
 }
 
 func (v Cluster) Progress() float64 {
-	return float64(v.HeapBlksScanned) / float64(v.HeapBlksTotal)
+	return v.Status().Overall
 }
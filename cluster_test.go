@@ -0,0 +1,48 @@
+package pgsp
+
+import "testing"
+
+func TestCluster_Status(t *testing.T) {
+	tests := []struct {
+		name      string
+		v         Cluster
+		wantIndex int
+		want      float64
+	}{
+		{
+			name:      "seqScan",
+			v:         Cluster{Command: "CLUSTER", PHASE: "seq scanning heap", ClusterIndexRelid: 2, HeapBlksTotal: 100, HeapBlksScanned: 50},
+			wantIndex: 1,
+			want:      2.0 / 11,
+		},
+		{
+			name:      "indexScan",
+			v:         Cluster{Command: "CLUSTER", PHASE: "index scanning heap", ClusterIndexRelid: 2, HeapTuplesScanned: 100},
+			wantIndex: 1,
+			want:      0,
+		},
+		{
+			name:      "indexScanRebuilding",
+			v:         Cluster{Command: "CLUSTER", PHASE: "rebuilding index", ClusterIndexRelid: 2, IndexRebuildCount: 1},
+			wantIndex: 3,
+			want:      7.5 / 10,
+		},
+		{
+			name:      "vacuumFull",
+			v:         Cluster{Command: "VACUUM FULL", PHASE: "swapping relation files", HeapBlksTotal: 100, HeapBlksScanned: 100},
+			wantIndex: 4,
+			want:      8.0 / 11,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.v.Status()
+			if got.Index != tt.wantIndex {
+				t.Errorf("Cluster.Status() Index = %v, want %v", got.Index, tt.wantIndex)
+			}
+			if got.Overall != tt.want {
+				t.Errorf("Cluster.Status() Overall = %v, want %v", got.Overall, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/noborus/pgsp"
+	"github.com/noborus/pgsp/demo"
+	"github.com/noborus/pgsp/tui"
+	"github.com/spf13/cobra"
+)
+
+var seed int64
+
+// demoCmd represents the demo command
+var demoCmd = &cobra.Command{
+	Use:   "demo [targets]",
+	Short: "Monitor a simulated server",
+	Long: `Monitors a simulated server that runs the commands of all the views
+without PostgreSQL. The same seed generates the same session.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		Demo(args)
+	},
+}
+
+func Demo(targets []string) {
+	setConfig()
+	if !validOutput() {
+		return
+	}
+	recorder, closeRecord, err := openRecord()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer closeRecord()
+
+	sim := demo.New(seed, time.Now())
+	if tui.UpdateInterval > 0 {
+		sim.Step = tui.UpdateInterval
+	}
+	sim.Targets(targets)
+	monitor([]pgsp.Server{{Collector: sim}}, recorder)
+}
+
+func init() {
+	rootCmd.AddCommand(demoCmd)
+	demoCmd.Flags().Int64Var(&seed, "seed", 1, "Seed of the simulation")
+}
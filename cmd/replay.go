@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/noborus/pgsp/output"
+	"github.com/noborus/pgsp/tui"
+	"github.com/spf13/cobra"
+)
+
+var speed string
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay file",
+	Short: "Replay a recorded session",
+	Long: `Replays a session recorded by --record (or --output json) in the terminal UI.
+space pauses, ←/→ seek, +/- change the speed.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		Replay(args[0])
+	},
+}
+
+func Replay(fileName string) {
+	setConfig()
+	registerSources()
+	s, err := parseSpeed(speed)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	snapshots, err := output.ReadSnapshots(f)
+	f.Close()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if len(snapshots) == 0 {
+		log.Printf("no snapshots in %s", fileName)
+		return
+	}
+
+	p := tui.NewProgram(tui.NewReplayModel(snapshots, s), config.FullScreen)
+	if err := p.Start(); err != nil {
+		fmt.Printf("there's been an error: %v", err)
+	}
+}
+
+// parseSpeed parses the speed such as "10x" or "0.5".
+func parseSpeed(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid speed: %s", s)
+	}
+	return v, nil
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+	replayCmd.Flags().StringVar(&speed, "speed", "1x", "Replay speed (e.g. 10x)")
+}
@@ -1,15 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	_ "github.com/lib/pq"
 
 	"github.com/noborus/pgsp"
+	"github.com/noborus/pgsp/output"
 	"github.com/noborus/pgsp/tui"
 
 	"github.com/spf13/cobra"
@@ -26,11 +31,25 @@ type Config struct {
 	AfterCompletion int     `yaml:"AfterCompletion"`
 	Interval        float64 `yaml:"Interval"`
 	FullScreen      bool    `yaml:"FullScreen"`
+	// Output is the output mode (tui, plain, json).
+	Output string `yaml:"Output"`
+	// Servers is the named servers to monitor instead of DSN.
+	Servers []ServerConfig `yaml:"Servers"`
+	// Sources is the user-defined progress sources.
+	Sources []pgsp.Source `yaml:"Sources"`
+}
+
+// ServerConfig is a named server to monitor.
+type ServerConfig struct {
+	Name string `yaml:"name"`
+	DSN  string `yaml:"dsn"`
 }
 
 var (
 	verFlag bool
 	debug   bool
+	// recordFile is the file to record the snapshots to.
+	recordFile string
 )
 
 var (
@@ -45,14 +64,25 @@ var rootCmd = &cobra.Command{
 	Use:   "pgsp",
 	Short: "pg_stat_progress monitor",
 	Long: `Monitors PostgreSQL's pg_stat_progress_*.
-Analyze, BaseBackup, Cluster, Copy, CreateIndex, Vacuum can be specified.
+` + targetNames() + ` can be specified.
 `,
 	Version: Version + " rev:" + Revision,
+	// The arguments are the targets besides the subcommands.
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		Progress(args)
 	},
 }
 
+// targetNames returns the registered targets separated by commas.
+func targetNames() string {
+	var names []string
+	for _, t := range pgsp.Registered() {
+		names = append(names, string(t))
+	}
+	return strings.Join(names, ", ")
+}
+
 func Progress(targets []string) {
 	setConfig()
 	if tui.Debug {
@@ -64,20 +94,64 @@ func Progress(targets []string) {
 		defer f.Close()
 	}
 
-	monitor, err := pgsp.New(config.DSN)
+	registerSources()
+	if !validOutput() {
+		return
+	}
+
+	recorder, closeRecord, err := openRecord()
 	if err != nil {
 		log.Println(err)
 		return
 	}
+	defer closeRecord()
+
+	servers, disconnect := connect(targets)
+	defer disconnect()
+	if len(servers) == 0 {
+		return
+	}
+	monitor(servers, recorder)
+}
+
+// validOutput returns true if the output mode of the config is valid.
+func validOutput() bool {
+	switch config.Output {
+	case "", "tui", "plain", "json":
+		return true
+	}
+	log.Printf("unknown output: %s", config.Output)
+	return false
+}
+
+// monitor watches the servers in the output mode of the config
+// and records the snapshots if recorder is not nil.
+func monitor(servers []pgsp.Server, recorder *output.Recorder) {
+	switch config.Output {
+	case "plain":
+		printSnapshots(servers, withRecorder(output.NewPlain(os.Stdout), recorder))
+		return
+	case "json":
+		printSnapshots(servers, withRecorder(output.NewJSON(os.Stdout), recorder))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	snapshots := watch(ctx, servers)
+	// Errors are reported after the terminal is restored.
+	errc := make(chan error, 1)
+	if recorder != nil {
+		snapshots = output.Tee(snapshots, recorder, func(err error) { errc <- err })
+	}
+	model := tui.NewSnapshotModel(snapshots, cancel)
 	defer func() {
-		if err := monitor.DisConnect(); err != nil {
+		select {
+		case err := <-errc:
 			log.Println(err)
+		default:
 		}
 	}()
 
-	monitor.Targets(targets)
-	model := tui.NewModel(monitor)
-
 	p := tui.NewProgram(model, config.FullScreen)
 	tui.DebugLog("Start")
 	defer tui.DebugLog("End")
@@ -87,6 +161,108 @@ func Progress(targets []string) {
 	}
 }
 
+// openRecord creates the file of --record and returns the recorder to it.
+// The recorder is nil without --record.
+func openRecord() (*output.Recorder, func(), error) {
+	if recordFile == "" {
+		return nil, func() {}, nil
+	}
+	f, err := os.Create(recordFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output.NewRecorder(f), func() {
+		if err := f.Close(); err != nil {
+			log.Println(err)
+		}
+	}, nil
+}
+
+// withRecorder returns the printer that also records the snapshots if recorder is not nil.
+func withRecorder(p output.Printer, recorder *output.Recorder) output.Printer {
+	if recorder == nil {
+		return p
+	}
+	return output.Multi{p, recorder}
+}
+
+// registerSources registers the user-defined sources of the config.
+func registerSources() {
+	for _, s := range config.Sources {
+		if err := pgsp.RegisterSource(s); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// connect connects to the servers of the config and enables the targets.
+// Servers that are unreachable are polled from the lost connection and reconnected,
+// and servers that fail otherwise (e.g. authentication) are skipped.
+func connect(targets []string) ([]pgsp.Server, func()) {
+	configs := config.Servers
+	if err := checkServers(configs); err != nil {
+		log.Println(err)
+		return nil, func() {}
+	}
+	if len(configs) == 0 {
+		configs = []ServerConfig{{DSN: config.DSN}}
+	}
+	var servers []pgsp.Server
+	var monitors []*pgsp.Pgsp
+	for _, c := range configs {
+		monitor, err := pgsp.Open(c.DSN)
+		if err != nil {
+			if c.Name != "" {
+				err = fmt.Errorf("%s: %w", c.Name, err)
+			}
+			log.Println(err)
+			continue
+		}
+		monitor.Targets(targets)
+		monitors = append(monitors, monitor)
+		servers = append(servers, pgsp.Server{Name: c.Name, Collector: monitor})
+	}
+	return servers, func() {
+		for _, monitor := range monitors {
+			if err := monitor.DisConnect(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// checkServers returns an error if a server of the config has no name
+// or the same name as another, by which the operations are grouped.
+func checkServers(configs []ServerConfig) error {
+	names := make(map[string]bool, len(configs))
+	for i, c := range configs {
+		if c.Name == "" {
+			return fmt.Errorf("servers[%d]: no name", i)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("servers[%d]: duplicate name %s", i, c.Name)
+		}
+		names[c.Name] = true
+	}
+	return nil
+}
+
+// printSnapshots watches the servers and prints the snapshots until interrupted.
+func printSnapshots(servers []pgsp.Server, p output.Printer) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := output.Run(watch(ctx, servers), p); err != nil {
+		log.Println(err)
+	}
+}
+
+// watch watches the servers with the config until ctx is done.
+func watch(ctx context.Context, servers []pgsp.Server) <-chan pgsp.Snapshot {
+	w := pgsp.NewServersWatcher(servers, tui.UpdateInterval)
+	w.Retention = time.Second * tui.AfterCompletion
+	return w.Watch(ctx)
+}
+
 func setConfig() {
 	tui.AfterCompletion = time.Duration(config.AfterCompletion)
 	tui.UpdateInterval = time.Duration(time.Millisecond * time.Duration(config.Interval*1000))
@@ -128,6 +304,12 @@ func init() {
 	rootCmd.PersistentFlags().Float64VarP(&interval, "Interval", "i", 0.5, "Update interval(Seconds)")
 	_ = viper.BindPFlag("Interval", rootCmd.PersistentFlags().Lookup("Interval"))
 
+	var outputMode string
+	rootCmd.PersistentFlags().StringVarP(&outputMode, "output", "o", "tui", "Output mode (tui, plain, json)")
+	_ = viper.BindPFlag("Output", rootCmd.PersistentFlags().Lookup("output"))
+
+	rootCmd.PersistentFlags().StringVar(&recordFile, "record", "", "Record the snapshots to the file (JSON Lines)")
+
 	var fullscreen bool
 	rootCmd.PersistentFlags().BoolVarP(&fullscreen, "fullscreen", "f", false, "Display in Full Screen")
 	_ = viper.BindPFlag("FullScreen", rootCmd.PersistentFlags().Lookup("fullscreen"))
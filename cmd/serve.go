@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/noborus/pgsp/output"
+	"github.com/noborus/pgsp/serve"
+	"github.com/spf13/cobra"
+)
+
+var listen string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve [targets]",
+	Short: "Serve the progress over HTTP",
+	Long: `Serves the progress over HTTP.
+/ is a dashboard that shows the operations updated live.
+/metrics exposes the operations in the Prometheus text format.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		Serve(args)
+	},
+}
+
+func Serve(targets []string) {
+	setConfig()
+	registerSources()
+	recorder, closeRecord, err := openRecord()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer closeRecord()
+	servers, disconnect := connect(targets)
+	defer disconnect()
+	if len(servers) == 0 {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	metrics := serve.NewMetrics()
+	dashboard := serve.NewDashboard()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.Handle("/", dashboard)
+	srv := &http.Server{Addr: listen, Handler: mux}
+	// The event streams do not end by themselves.
+	srv.RegisterOnShutdown(dashboard.Close)
+
+	snapshots := watch(ctx, servers)
+	if recorder != nil {
+		// An error of the recording stops only the recording.
+		snapshots = output.Tee(snapshots, recorder, func(err error) { log.Println(err) })
+	}
+	go func() {
+		if err := output.Run(snapshots, output.Multi{metrics, dashboard}); err != nil {
+			log.Println(err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	log.Printf("Listening on %s", listen)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Println(err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&listen, "listen", ":9187", "Address to listen on")
+}
@@ -0,0 +1,168 @@
+package pgsp
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// collectQuery builds a query that returns the rows of all targets in one round trip.
+// Each row is returned as JSON with the name of the target.
+func (p *Pgsp) collectQuery(targets []SPTaget) string {
+	buff := new(bytes.Buffer)
+	for i, target := range targets {
+		if i > 0 {
+			buff.WriteString("\nUNION ALL\n")
+		}
+		fmt.Fprintf(buff, "SELECT %s AS target, to_json(r)::text AS row FROM (%s) r", pq.QuoteLiteral(string(target)), p.StatProgress[target].Query())
+	}
+	return buff.String()
+}
+
+// prepare returns the prepared statement of the query.
+// Statements are prepared once for each set of targets.
+func (p *Pgsp) prepare(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if stmt, ok := p.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := p.DB.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if p.stmts == nil {
+		p.stmts = make(map[string]*sqlx.Stmt)
+	}
+	p.stmts[query] = stmt
+	return stmt, nil
+}
+
+// closeStmts closes the prepared statements.
+func (p *Pgsp) closeStmts() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for query, stmt := range p.stmts {
+		stmt.Close()
+		delete(p.stmts, query)
+	}
+}
+
+// Collect returns the rows of the targets in one round trip.
+func (p *Pgsp) Collect(ctx context.Context, targets []SPTaget) (map[SPTaget][]Progress, error) {
+	if len(targets) == 0 {
+		return map[SPTaget][]Progress{}, nil
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+	stmt, err := p.prepare(ctx, p.collectQuery(targets))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryxContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[SPTaget][]Progress, len(targets))
+	for _, target := range targets {
+		result[target] = nil
+	}
+	for rows.Next() {
+		var target, row string
+		if err := rows.Scan(&target, &row); err != nil {
+			return nil, err
+		}
+		table, ok := p.StatProgress[SPTaget(target)]
+		if !ok {
+			return nil, fmt.Errorf("unknown target: %s", target)
+		}
+		v, err := table.Decode([]byte(row))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", target, err)
+		}
+		if r, ok := v.(Resolvable); ok {
+			v = r.Resolve(ctx, p.Resolver)
+		}
+		result[SPTaget(target)] = append(result[SPTaget(target)], v)
+	}
+	return result, rows.Err()
+}
+
+// DecodeAs decodes a JSON row into T.
+func DecodeAs[T Progress](data []byte) (Progress, error) {
+	var v T
+	if err := decodeRow(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeRow decodes a JSON object into the fields of the row pointed by dst
+// using the column names as the keys.
+func decodeRow(data []byte, dst interface{}) error {
+	f, ok := dst.(fielder)
+	if !ok {
+		return fmt.Errorf("cannot decode a row into %T", dst)
+	}
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return err
+	}
+	for column, raw := range row {
+		p := f.field(column)
+		if p == nil || string(raw) == "null" {
+			continue
+		}
+		if err := decodeValue(raw, p); err != nil {
+			return fmt.Errorf("%s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+func decodeValue(raw json.RawMessage, dst interface{}) error {
+	switch d := dst.(type) {
+	case *sql.NullString:
+		d.Valid = true
+		return json.Unmarshal(raw, &d.String)
+	case *sql.NullInt64:
+		d.Valid = true
+		return json.Unmarshal(raw, &d.Int64)
+	case *sql.NullInt32:
+		d.Valid = true
+		return json.Unmarshal(raw, &d.Int32)
+	case *sql.NullTime:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		t, err := parseTime(s)
+		if err != nil {
+			return err
+		}
+		d.Time, d.Valid = t, true
+		return nil
+	}
+	return json.Unmarshal(raw, dst)
+}
+
+// parseTime parses the timestamp with time zone of to_json.
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err == nil {
+		return t, nil
+	}
+	// The minutes of the time zone offset may be omitted ("+09").
+	if n := len(s); n > 3 && (s[n-3] == '+' || s[n-3] == '-') {
+		return time.Parse(time.RFC3339Nano, s+":00")
+	}
+	return t, err
+}
@@ -0,0 +1,92 @@
+package pgsp
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeAs(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.FixedZone("", 9*60*60))
+	tests := []struct {
+		name    string
+		data    string
+		decode  func([]byte) (Progress, error)
+		want    Progress
+		wantErr bool
+	}{
+		{
+			name:   "vacuum",
+			data:   `{"pid":10,"datid":5,"datname":"postgres","relid":16384,"phase":"scanning heap","heap_blks_total":100,"heap_blks_scanned":10,"usename":"postgres","backend_type":"autovacuum worker","query_start":"2024-01-02T03:04:05.6+09:00","wait_event":null}`,
+			decode: DecodeAs[Vacuum],
+			want: Vacuum{
+				PID:             10,
+				DATID:           5,
+				DATNAME:         "postgres",
+				RELID:           16384,
+				PHASE:           "scanning heap",
+				HeapBLKSTotal:   100,
+				HeapBLKSScanned: 10,
+				Activity: Activity{
+					Usename:     sql.NullString{String: "postgres", Valid: true},
+					BackendType: sql.NullString{String: "autovacuum worker", Valid: true},
+					QueryStart:  sql.NullTime{Time: start, Valid: true},
+				},
+			},
+		},
+		{
+			name:   "basebackupNull",
+			data:   `{"pid":1,"phase":"streaming database files","backup_total":null,"backup_streamed":1024}`,
+			decode: DecodeAs[BaseBackup],
+			want: BaseBackup{
+				PID:            1,
+				PHASE:          "streaming database files",
+				BackupStreamed: 1024,
+			},
+		},
+		{
+			name:    "invalid",
+			data:    `{"pid":"a"}`,
+			decode:  DecodeAs[Copy],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.decode([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeAs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if g, ok := got.(Vacuum); ok {
+				w := tt.want.(Vacuum)
+				if !g.QueryStart.Time.Equal(w.QueryStart.Time) {
+					t.Errorf("DecodeAs() QueryStart = %v, want %v", g.QueryStart.Time, w.QueryStart.Time)
+				}
+				g.QueryStart.Time = w.QueryStart.Time
+				got = g
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeAs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPgsp_collectQuery(t *testing.T) {
+	p := &Pgsp{StatProgress: NewMonitor()}
+	got := p.collectQuery([]SPTaget{SPCopy, SPVacuum})
+	for _, want := range []string{
+		"SELECT 'Copy' AS target, to_json(r)::text AS row FROM (SELECT p.pid,",
+		" FROM pg_stat_progress_copy p LEFT JOIN pg_stat_activity a ON a.pid = p.pid) r\nUNION ALL\n",
+		"SELECT 'Vacuum' AS target",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("collectQuery() = %v, want contains %v", got, want)
+		}
+	}
+}
@@ -1,14 +1,10 @@
 package pgsp
 
 import (
-	"bytes"
 	"context"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	"github.com/noborus/pgsp/str"
-	"github.com/noborus/pgsp/vertical"
-	"github.com/olekukonko/tablewriter"
 )
 
 // pg_stat_progress_copy
@@ -23,41 +19,25 @@ type Copy struct {
 	BYTESTotal      int64  `db:"bytes_total"`
 	TUPLESProcessed int64  `db:"tuples_processed"`
 	TUPLESExcluded  int64  `db:"tuples_excluded"`
+	// Resolved names of the OIDs.
+	RelName string `db:"-"`
+	// Session of the backend.
+	Activity
+	// Columns selected for the server version.
+	viewColumns
 }
 
-var (
-	CopyTableName = "pg_stat_progress_copy"
-	CopyQuery     string
-	CopyColumns   []string
-)
+var CopyTableName = "pg_stat_progress_copy"
 
-func GetCopy(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
-	if len(CopyColumns) == 0 {
-		CopyColumns = getColumns(Copy{})
-	}
-	if CopyQuery == "" {
-		CopyQuery = buildQuery(CopyTableName, CopyColumns)
-	}
-	return selectCopy(ctx, db, CopyQuery)
+// CopyVersionColumns is the columns of pg_stat_progress_copy for each server version.
+var CopyVersionColumns = VersionColumns{
+	140000: getColumns(Copy{}),
 }
 
-func selectCopy(ctx context.Context, db *sqlx.DB, query string) ([]Progress, error) {
-	rows, err := db.QueryxContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var as []Progress
-	for rows.Next() {
-		var row Copy
-		err = rows.StructScan(&row)
-		if err != nil {
-			return nil, err
-		}
-		as = append(as, row)
-	}
-	return as, nil
+// GetCopy returns the rows of pg_stat_progress_copy with the columns of the latest server version.
+// Pgsp.Get selects the columns of the connected server.
+func GetCopy(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
+	return getRows[Copy](ctx, db, CopyTableName, CopyVersionColumns)
 }
 
 func (v Copy) Name() string {
@@ -68,37 +48,99 @@ func (v Copy) Pid() int {
 	return v.PID
 }
 
+func (v Copy) Relation() string {
+	return relationName(v.RelName, v.RELID)
+}
+
+func (v Copy) RelationID() (int, int) {
+	return v.DATID, v.RELID
+}
+
+func (v Copy) Resolve(ctx context.Context, r *Resolver) Progress {
+	v.RelName = r.Name(ctx, v.DATID, v.RELID)
+	return v
+}
+
 func (v Copy) Color() (string, string) {
 	return "#5AF6FF", "#7CFFCB"
 }
 
 func (v Copy) Table() string {
-	value := str.ToStrStruct(v)
-	buff := new(bytes.Buffer)
-	t := tablewriter.NewWriter(buff)
-	t.SetHeader(CopyColumns[0:7])
-	t.Append(value[0:7])
-	t.Render()
-
-	t2 := tablewriter.NewWriter(buff)
-	t2.SetHeader(CopyColumns[7:])
-	t2.Append(value[7:])
-	t2.Render()
-	return buff.String()
+	return renderTable(&v, 7)
 }
 
 func (v Copy) Vertical() string {
-	buff := new(bytes.Buffer)
-	vt := vertical.NewWriter(buff)
-	vt.SetHeader(CopyColumns)
-	vt.AppendStruct(v)
-	vt.Render()
-	return buff.String()
+	return renderVertical(&v)
 }
 
-func (v Copy) Progress() float64 {
-	if v.BYTESTotal == 0 {
-		return float64(0.5)
+// selectedColumns returns the columns of the view selected for the server version.
+func (v Copy) selectedColumns() []string {
+	return v.selected(CopyVersionColumns)
+}
+
+// names returns the resolved names of the OID columns.
+func (v Copy) names() map[string]string {
+	return map[string]string{
+		"relid": v.RelName,
 	}
-	return float64(v.BYTESProcessed) / float64(v.BYTESTotal)
+}
+
+// field returns the pointer to the field of the column.
+func (v *Copy) field(column string) interface{} {
+	switch column {
+	case "pid":
+		return &v.PID
+	case "datid":
+		return &v.DATID
+	case "datname":
+		return &v.DATNAME
+	case "relid":
+		return &v.RELID
+	case "command":
+		return &v.COMMAND
+	case "type":
+		return &v.CTYPE
+	case "bytes_processed":
+		return &v.BYTESProcessed
+	case "bytes_total":
+		return &v.BYTESTotal
+	case "tuples_processed":
+		return &v.TUPLESProcessed
+	case "tuples_excluded":
+		return &v.TUPLESExcluded
+	}
+	return v.Activity.field(column)
+}
+
+// row returns the selected columns and the session by the column names.
+func (v Copy) row() map[string]interface{} {
+	return rowOf(&v, v.selectedColumns())
+}
+
+// withColumns returns the row with the columns selected for a server version.
+func (v Copy) withColumns(columns []string) Progress {
+	v.columns = knownColumns(&v, columns)
+	return v
+}
+
+// Phase returns the command because COPY has no phase.
+func (v Copy) Phase() string {
+	return v.COMMAND
+}
+
+func (v Copy) Counter() Counter {
+	return Counter{Done: v.BYTESProcessed, Total: v.BYTESTotal, Unit: UnitBytes}
+}
+
+// Status returns the status without phases because COPY has no phase.
+// The total is unknown for COPY FROM STDIN.
+func (v Copy) Status() Status {
+	var ps Phases
+	s := ps.Status(v.Phase(), v.Counter())
+	s.Extra = []Counter{{Done: v.TUPLESProcessed, Unit: UnitTuples}}
+	return s
+}
+
+func (v Copy) Progress() float64 {
+	return v.Status().Overall
 }
@@ -63,10 +63,52 @@ func TestCopy_Vertical(t *testing.T) {
 				TUPLESProcessed: tt.fields.TUPLESProcessed,
 				TUPLESExcluded:  tt.fields.TUPLESExcluded,
 			}
-			CopyColumns = getColumns(Copy{})
 			if got := v.Vertical(); got != tt.want {
 				t.Errorf("Copy.Vertical() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestCopy_Status(t *testing.T) {
+	tests := []struct {
+		name              string
+		v                 Copy
+		wantIndeterminate bool
+		wantProgress      float64
+	}{
+		{
+			name: "stdin",
+			v: Copy{
+				COMMAND:         "COPY FROM",
+				CTYPE:           "PIPE",
+				BYTESProcessed:  1024,
+				TUPLESProcessed: 10,
+			},
+			wantIndeterminate: true,
+			wantProgress:      0,
+		},
+		{
+			name: "file",
+			v: Copy{
+				COMMAND:        "COPY FROM",
+				CTYPE:          "FILE",
+				BYTESProcessed: 25,
+				BYTESTotal:     100,
+			},
+			wantIndeterminate: false,
+			wantProgress:      0.25,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.v.Status()
+			if got.Indeterminate != tt.wantIndeterminate {
+				t.Errorf("Copy.Status() Indeterminate = %v, want %v", got.Indeterminate, tt.wantIndeterminate)
+			}
+			if p := tt.v.Progress(); p != tt.wantProgress {
+				t.Errorf("Copy.Progress() = %v, want %v", p, tt.wantProgress)
+			}
+		})
+	}
+}
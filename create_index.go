@@ -1,14 +1,11 @@
 package pgsp
 
 import (
-	"bytes"
 	"context"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	"github.com/noborus/pgsp/str"
-	"github.com/noborus/pgsp/vertical"
-	"github.com/olekukonko/tablewriter"
 )
 
 // pg_stat_progress_create_index
@@ -29,42 +26,26 @@ type CreateIndex struct {
 	TuplesDone      int64  `db:"tuples_done"`
 	PartitionsTotal int64  `db:"partitions_total"`
 	PartitionsDone  int64  `db:"partitions_done"`
+	// Resolved names of the OIDs.
+	RelName   string `db:"-"`
+	IndexName string `db:"-"`
+	// Session of the backend.
+	Activity
+	// Columns selected for the server version.
+	viewColumns
 }
 
 var CreateIndexTableName = "pg_stat_progress_create_index"
 
-var (
-	CreateIndexQuery   string
-	CreateIndexColumns []string
-)
-
-func GetCreateIndex(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
-	if len(CreateIndexColumns) == 0 {
-		CreateIndexColumns = getColumns(CreateIndex{})
-	}
-	if CreateIndexQuery == "" {
-		CreateIndexQuery = buildQuery(CreateIndexTableName, CreateIndexColumns)
-	}
-	return selectCreateIndex(ctx, db, CreateIndexQuery)
+// CreateIndexVersionColumns is the columns of pg_stat_progress_create_index for each server version.
+var CreateIndexVersionColumns = VersionColumns{
+	120000: getColumns(CreateIndex{}),
 }
 
-func selectCreateIndex(ctx context.Context, db *sqlx.DB, query string) ([]Progress, error) {
-	rows, err := db.QueryxContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var as []Progress
-	for rows.Next() {
-		var row CreateIndex
-		err = rows.StructScan(&row)
-		if err != nil {
-			return nil, err
-		}
-		as = append(as, row)
-	}
-	return as, rows.Err()
+// GetCreateIndex returns the rows of pg_stat_progress_create_index with the columns of the latest server version.
+// Pgsp.Get selects the columns of the connected server.
+func GetCreateIndex(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
+	return getRows[CreateIndex](ctx, db, CreateIndexTableName, CreateIndexVersionColumns)
 }
 
 func (v CreateIndex) Name() string {
@@ -75,43 +56,132 @@ func (v CreateIndex) Pid() int {
 	return v.PID
 }
 
+func (v CreateIndex) Relation() string {
+	return relationName(v.RelName, v.RELID)
+}
+
+func (v CreateIndex) RelationID() (int, int) {
+	return v.DATID, v.RELID
+}
+
+func (v CreateIndex) Resolve(ctx context.Context, r *Resolver) Progress {
+	v.RelName = r.Name(ctx, v.DATID, v.RELID)
+	v.IndexName = r.Name(ctx, v.DATID, v.IndexRelid)
+	return v
+}
+
 func (v CreateIndex) Color() (string, string) {
 	return "#EE6FF8", "#5A56E0"
 }
 
 func (v CreateIndex) Table() string {
-	value := str.ToStrStruct(v)
-	buff := new(bytes.Buffer)
+	return renderTable(&v, 9)
+}
 
-	t := tablewriter.NewWriter(buff)
-	t.SetHeader(CreateIndexColumns[0:9])
-	t.Append(value[0:9])
-	t.Render()
+func (v CreateIndex) Vertical() string {
+	return renderVertical(&v)
+}
 
-	t2 := tablewriter.NewWriter(buff)
-	t2.SetHeader(CreateIndexColumns[9:])
-	t2.Append(value[9:])
-	t2.Render()
+// selectedColumns returns the columns of the view selected for the server version.
+func (v CreateIndex) selectedColumns() []string {
+	return v.selected(CreateIndexVersionColumns)
+}
 
-	return buff.String()
+// names returns the resolved names of the OID columns.
+func (v CreateIndex) names() map[string]string {
+	return map[string]string{
+		"relid":       v.RelName,
+		"index_relid": v.IndexName,
+	}
 }
 
-func (v CreateIndex) Vertical() string {
-	buff := new(bytes.Buffer)
-	vt := vertical.NewWriter(buff)
-	vt.SetHeader(CreateIndexColumns)
-	vt.AppendStruct(v)
-	vt.Render()
+// field returns the pointer to the field of the column.
+func (v *CreateIndex) field(column string) interface{} {
+	switch column {
+	case "pid":
+		return &v.PID
+	case "datid":
+		return &v.DATID
+	case "datname":
+		return &v.DATNAME
+	case "relid":
+		return &v.RELID
+	case "index_relid":
+		return &v.IndexRelid
+	case "command":
+		return &v.Command
+	case "phase":
+		return &v.PHASE
+	case "lockers_total":
+		return &v.LockersTotal
+	case "lockers_done":
+		return &v.LockersDone
+	case "current_locker_pid":
+		return &v.LockersPid
+	case "blocks_total":
+		return &v.BlocksTotal
+	case "blocks_done":
+		return &v.BlocksDone
+	case "tuples_total":
+		return &v.TuplesTotal
+	case "tuples_done":
+		return &v.TuplesDone
+	case "partitions_total":
+		return &v.PartitionsTotal
+	case "partitions_done":
+		return &v.PartitionsDone
+	}
+	return v.Activity.field(column)
+}
 
-	return buff.String()
+// row returns the selected columns and the session by the column names.
+func (v CreateIndex) row() map[string]interface{} {
+	return rowOf(&v, v.selectedColumns())
 }
 
-func (v CreateIndex) Progress() float64 {
-	if v.BlocksTotal != 0 {
-		return float64(v.BlocksDone) / float64(v.BlocksTotal)
+// withColumns returns the row with the columns selected for a server version.
+func (v CreateIndex) withColumns(columns []string) Progress {
+	v.columns = knownColumns(&v, columns)
+	return v
+}
+
+func (v CreateIndex) Phase() string {
+	return v.PHASE
+}
+
+func (v CreateIndex) Counter() Counter {
+	switch {
+	case strings.HasPrefix(v.PHASE, "waiting for"):
+		return Counter{Done: v.LockersDone, Total: v.LockersTotal, Unit: "lockers"}
+	case strings.Contains(v.PHASE, "sorting"):
+		// The blocks of the scan remain reported while sorting.
+		return Counter{}
+	case strings.Contains(v.PHASE, "loading tuples") && v.TuplesTotal != 0:
+		return Counter{Done: v.TuplesDone, Total: v.TuplesTotal, Unit: UnitTuples}
+	case v.BlocksTotal != 0:
+		return Counter{Done: v.BlocksDone, Total: v.BlocksTotal, Unit: UnitBlocks}
+	case v.TuplesTotal != 0:
+		return Counter{Done: v.TuplesDone, Total: v.TuplesTotal, Unit: UnitTuples}
+	}
+	return Counter{}
+}
+
+func (v CreateIndex) Status() Status {
+	phases := CreateIndexPhases
+	if strings.HasSuffix(v.Command, "CONCURRENTLY") {
+		phases = CreateIndexConcurrentlyPhases
 	}
+	s := phases.Status(v.PHASE, v.Counter())
 	if v.PartitionsTotal != 0 {
-		return float64(v.PartitionsDone) / float64(v.PartitionsTotal)
+		// The phases are repeated for each partition.
+		s.Overall = (float64(v.PartitionsDone) + s.Overall) / float64(v.PartitionsTotal)
+		if s.Overall > 1 {
+			s.Overall = 1
+		}
 	}
-	return float64(v.TuplesDone) / float64(v.TuplesTotal)
+	return s
+}
+
+func (v CreateIndex) Progress() float64 {
+	return v.Status().Overall
 }
@@ -66,10 +66,33 @@ func TestCreateIndex_Table(t *testing.T) {
 				PartitionsTotal: tt.fields.PartitionsTotal,
 				PartitionsDone:  tt.fields.PartitionsDone,
 			}
-			CreateIndexColumns = getColumns(CreateIndex{})
 			if got := v.Table(); got != tt.want {
 				t.Errorf("CreateIndex.Table() = \n%v\n, want \n%v\n", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestCreateIndex_Status(t *testing.T) {
+	// The progress of CREATE INDEX on btree must not go backwards across the sub phases.
+	rows := []CreateIndex{
+		{Command: "CREATE INDEX", PHASE: "initializing"},
+		{Command: "CREATE INDEX", PHASE: "building index: scanning table", BlocksTotal: 100, BlocksDone: 50},
+		{Command: "CREATE INDEX", PHASE: "building index: scanning table", BlocksTotal: 100, BlocksDone: 100},
+		{Command: "CREATE INDEX", PHASE: "building index: sorting live tuples", BlocksTotal: 100, BlocksDone: 100},
+		{Command: "CREATE INDEX", PHASE: "building index: loading tuples in tree", BlocksTotal: 100, BlocksDone: 100, TuplesTotal: 1000},
+		{Command: "CREATE INDEX", PHASE: "building index: loading tuples in tree", BlocksTotal: 100, BlocksDone: 100, TuplesTotal: 1000, TuplesDone: 500},
+		{Command: "CREATE INDEX", PHASE: "building index: loading tuples in tree", BlocksTotal: 100, BlocksDone: 100, TuplesTotal: 1000, TuplesDone: 1000},
+	}
+	prev := -1.0
+	for _, v := range rows {
+		p := v.Progress()
+		if p < prev {
+			t.Errorf("CreateIndex.Progress() = %v at %q %v, went back from %v", p, v.PHASE, v.Counter(), prev)
+		}
+		prev = p
+	}
+	if prev != 1 {
+		t.Errorf("CreateIndex.Progress() = %v at the end, want 1", prev)
+	}
+}
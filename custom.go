@@ -0,0 +1,249 @@
+package pgsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/noborus/pgsp/vertical"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Source is a progress source defined by a query, such as a progress table of batch jobs.
+// The expressions refer to the columns of SQL.
+type Source struct {
+	Name string
+	// SQL returns a row for each running operation.
+	SQL string
+	// Pid is the expression of the pid of the backend (default pid).
+	Pid string
+	// Done and Total are the expressions of the amount of work.
+	// The progress is indeterminate without Total.
+	Done  string
+	Total string
+	// Unit is the unit of Done and Total.
+	Unit string
+	// Phase is the expression of the current phase.
+	Phase string
+	// Phases is the names of the phases in the order of execution.
+	Phases []string
+	// Colors is the start and end colors of the progress bar.
+	Colors []string
+}
+
+// RegisterSource registers the source by its name.
+func RegisterSource(s Source) error {
+	if s.Name == "" {
+		return errors.New("pgsp: source without name")
+	}
+	if s.SQL == "" {
+		return fmt.Errorf("pgsp: source %s: no sql", s.Name)
+	}
+	src := &s
+	return register(SPTaget(s.Name), func() SPTable {
+		return SPTable{
+			Get:    src.Get,
+			Query:  src.Query,
+			Decode: src.Decode,
+		}
+	})
+}
+
+// Query returns the query of the source with the pid, counter, phase
+// and session of each row in hidden columns.
+func (s *Source) Query() string {
+	pid := s.Pid
+	if pid == "" {
+		pid = "pid"
+	}
+	return fmt.Sprintf("SELECT s.*, (SELECT to_json(x)::text FROM (SELECT %s FROM pg_stat_activity a WHERE a.pid = s.__pid) x) AS __activity"+
+		" FROM (SELECT t.*, (%s)::int AS __pid, (%s)::bigint AS __done, (%s)::bigint AS __total, (%s)::text AS __phase FROM (%s) t) s",
+		strings.Join(activityColumns, ", "), pid, orNull(s.Done), orNull(s.Total), orNull(s.Phase), s.SQL)
+}
+
+func orNull(expr string) string {
+	if expr == "" {
+		return "NULL"
+	}
+	return expr
+}
+
+func (s *Source) Get(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
+	rows, err := db.QueryxContext(ctx, "SELECT to_json(r)::text AS row FROM ("+s.Query()+") r")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var as []Progress
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return nil, err
+		}
+		v, err := s.Decode([]byte(row))
+		if err != nil {
+			return nil, err
+		}
+		as = append(as, v)
+	}
+	return as, rows.Err()
+}
+
+// Decode decodes a row of Query returned as JSON.
+// The columns of SQL are kept in order for display.
+func (s *Source) Decode(data []byte) (Progress, error) {
+	v := Custom{source: s}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %v", t)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if err := v.set(key, raw); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return v, nil
+}
+
+// Custom is a row of a Source.
+type Custom struct {
+	source    *Source
+	Columns   []string
+	Values    []string
+	PID       int
+	Done      *int64
+	Total     *int64
+	PhaseName string
+	// Session of the backend.
+	Activity
+}
+
+func (v *Custom) set(key string, raw json.RawMessage) error {
+	switch key {
+	case "__pid":
+		return json.Unmarshal(raw, &v.PID)
+	case "__done":
+		return json.Unmarshal(raw, &v.Done)
+	case "__total":
+		return json.Unmarshal(raw, &v.Total)
+	case "__phase":
+		return json.Unmarshal(raw, &v.PhaseName)
+	case "__activity":
+		var a *string
+		if err := json.Unmarshal(raw, &a); err != nil || a == nil {
+			return err
+		}
+		return decodeRow([]byte(*a), &v.Activity)
+	}
+	v.Columns = append(v.Columns, key)
+	var s string
+	switch {
+	case bytes.Equal(raw, []byte("null")):
+	case json.Unmarshal(raw, &s) == nil:
+	default:
+		s = string(raw)
+	}
+	v.Values = append(v.Values, s)
+	return nil
+}
+
+// row returns the columns of SQL and the hidden columns as Decode reads them.
+func (v Custom) row() map[string]interface{} {
+	row := make(map[string]interface{}, len(v.Columns)+5)
+	for i, c := range v.Columns {
+		row[c] = v.Values[i]
+	}
+	row["__pid"] = v.PID
+	row["__done"] = v.Done
+	row["__total"] = v.Total
+	row["__phase"] = v.PhaseName
+	if a, err := json.Marshal(rowOf(&v.Activity, nil)); err == nil {
+		row["__activity"] = string(a)
+	}
+	return row
+}
+
+func (v Custom) Name() string {
+	return v.source.Name
+}
+
+func (v Custom) Pid() int {
+	return v.PID
+}
+
+func (v Custom) Relation() string {
+	return ""
+}
+
+func (v Custom) Color() (string, string) {
+	if len(v.source.Colors) >= 2 {
+		return v.source.Colors[0], v.source.Colors[1]
+	}
+	return "#B8B8B8", "#F2F2F2"
+}
+
+func (v Custom) Table() string {
+	buff := new(bytes.Buffer)
+	t := tablewriter.NewWriter(buff)
+	t.SetHeader(v.Columns)
+	t.Append(v.Values)
+	t.Render()
+	return buff.String()
+}
+
+func (v Custom) Vertical() string {
+	values := make([]interface{}, len(v.Values))
+	for i, s := range v.Values {
+		values[i] = s
+	}
+	buff := new(bytes.Buffer)
+	vt := vertical.NewWriter(buff)
+	vt.SetHeader(v.Columns)
+	vt.Append(values)
+	vt.Render()
+	return buff.String()
+}
+
+func (v Custom) Phase() string {
+	return v.PhaseName
+}
+
+func (v Custom) Counter() Counter {
+	c := Counter{Unit: v.source.Unit}
+	if v.Done != nil {
+		c.Done = *v.Done
+	}
+	if v.Total != nil {
+		c.Total = *v.Total
+	}
+	return c
+}
+
+func (v Custom) Status() Status {
+	ps := make(Phases, len(v.source.Phases))
+	for i, name := range v.source.Phases {
+		ps[i] = PhaseWeight{Name: name, Weight: 1}
+	}
+	return ps.Status(v.Phase(), v.Counter())
+}
+
+func (v Custom) Progress() float64 {
+	return v.Status().Overall
+}
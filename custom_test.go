@@ -0,0 +1,71 @@
+package pgsp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSource_Decode(t *testing.T) {
+	s := &Source{
+		Name:   "BatchJob",
+		SQL:    "SELECT * FROM batch_progress",
+		Unit:   UnitTuples,
+		Phases: []string{"load", "transform"},
+	}
+	tests := []struct {
+		name      string
+		data      string
+		want      Custom
+		wantPhase int
+		overall   float64
+	}{
+		{
+			name: "running",
+			data: `{"job":"nightly","pid":42,"step":"transform","rows":50,"__pid":42,"__done":50,"__total":100,"__phase":"transform",` +
+				`"__activity":"{\"usename\":\"etl\",\"backend_type\":\"client backend\"}"}`,
+			want: Custom{
+				Columns:   []string{"job", "pid", "step", "rows"},
+				Values:    []string{"nightly", "42", "transform", "50"},
+				PID:       42,
+				PhaseName: "transform",
+			},
+			wantPhase: 1,
+			overall:   0.75,
+		},
+		{
+			name: "indeterminate",
+			data: `{"job":null,"__pid":7,"__done":null,"__total":null,"__phase":null,"__activity":null}`,
+			want: Custom{
+				Columns: []string{"job"},
+				Values:  []string{""},
+				PID:     7,
+			},
+			wantPhase: -1,
+			overall:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := s.Decode([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			got := p.(Custom)
+			if !reflect.DeepEqual(got.Columns, tt.want.Columns) || !reflect.DeepEqual(got.Values, tt.want.Values) {
+				t.Errorf("Decode() = %v %v, want %v %v", got.Columns, got.Values, tt.want.Columns, tt.want.Values)
+			}
+			if got.Pid() != tt.want.PID || got.Phase() != tt.want.PhaseName {
+				t.Errorf("Decode() pid, phase = %d %q, want %d %q", got.Pid(), got.Phase(), tt.want.PID, tt.want.PhaseName)
+			}
+			st := got.Status()
+			if st.Index != tt.wantPhase || st.Overall != tt.overall {
+				t.Errorf("Status() index, overall = %d %v, want %d %v", st.Index, st.Overall, tt.wantPhase, tt.overall)
+			}
+		})
+	}
+
+	p, _ := s.Decode([]byte(tests[0].data))
+	if a := p.Session(); a.Usename.String != "etl" || a.BackendType.String != "client backend" {
+		t.Errorf("Session() = %+v", a)
+	}
+}
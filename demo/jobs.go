@@ -0,0 +1,536 @@
+package demo
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/noborus/pgsp"
+)
+
+// stage is a phase of a simulated command.
+type stage struct {
+	phase string
+	// total is the amount of work of the stage done at a constant rate.
+	total int64
+	// seconds is the time the stage takes.
+	seconds float64
+	// part is the index of the partition or the child table of the stage.
+	part int
+}
+
+// job is a simulated command.
+type job struct {
+	target pgsp.SPTaget
+	name   string
+	pid    int
+	stages []stage
+	// index is the index of the current stage.
+	index int
+	// elapsed is the time in the current stage in seconds.
+	elapsed float64
+	// failAt is the index of the stage in which the command fails, or -1.
+	failAt  int
+	failure pgsp.Outcome
+	// row returns the row of the view of the current state.
+	row func(j *job) pgsp.Progress
+}
+
+// advance advances the command by seconds.
+// Returns the outcome and true if the command has finished.
+func (j *job) advance(seconds float64) (pgsp.Outcome, bool) {
+	j.elapsed += seconds
+	for j.elapsed >= j.stages[j.index].seconds {
+		if j.index == j.failAt {
+			return j.failure, true
+		}
+		j.elapsed -= j.stages[j.index].seconds
+		j.index++
+		if j.index >= len(j.stages) {
+			return pgsp.OutcomeCompleted, true
+		}
+	}
+	// Fail halfway through the stage.
+	if j.index == j.failAt && j.elapsed >= j.stages[j.index].seconds/2 {
+		return j.failure, true
+	}
+	return pgsp.OutcomeUnknown, false
+}
+
+// stage returns the current stage.
+func (j *job) stage() stage {
+	return j.stages[j.index]
+}
+
+// done returns the amount of work done in the current stage.
+func (j *job) done() int64 {
+	st := j.stage()
+	if st.seconds <= 0 {
+		return st.total
+	}
+	d := int64(float64(st.total) * j.elapsed / st.seconds)
+	if d > st.total {
+		d = st.total
+	}
+	return d
+}
+
+// progress returns the amount of work of the phase in the current part:
+// done in the phase, the total after the phase and 0 before the phase.
+func (j *job) progress(phase string) int64 {
+	cur := j.stage()
+	for i, st := range j.stages {
+		if st.phase != phase || st.part != cur.part {
+			continue
+		}
+		switch {
+		case i < j.index:
+			return st.total
+		case i == j.index:
+			return j.done()
+		}
+		return 0
+	}
+	return 0
+}
+
+// relation is a simulated table.
+type relation struct {
+	datid   int
+	datname string
+	relid   int
+	name    string
+}
+
+var relations = []relation{
+	{16384, "app", 16401, "public.orders"},
+	{16384, "app", 16422, "public.order_items"},
+	{16384, "app", 16453, "public.customers"},
+	{16384, "app", 16480, "audit.events"},
+	{16385, "bench", 16501, "public.pgbench_accounts"},
+	{16385, "bench", 16510, "public.pgbench_history"},
+}
+
+// partitioned is a simulated partitioned table whose partitions follow the relid.
+var partitioned = relation{16384, "app", 16600, "public.measurements"}
+
+func partitionName(i int) string {
+	return fmt.Sprintf("public.measurements_y2024m%02d", i+1)
+}
+
+func (s *Simulator) relation() relation {
+	return relations[s.rng.Intn(len(relations))]
+}
+
+// between returns a random number in [min, max).
+func (s *Simulator) between(min, max float64) float64 {
+	return min + s.rng.Float64()*(max-min)
+}
+
+// client returns the session of a client backend running the query.
+func (s *Simulator) client(query string) pgsp.Activity {
+	users := []string{"postgres", "app", "dba"}
+	apps := []string{"psql", "pgAdmin 4", "migrate"}
+	return pgsp.Activity{
+		Usename:         valid(users[s.rng.Intn(len(users))]),
+		ApplicationName: valid(apps[s.rng.Intn(len(apps))]),
+		ClientAddr:      valid(fmt.Sprintf("10.0.0.%d", 2+s.rng.Intn(200))),
+		BackendType:     valid("client backend"),
+		Query:           valid(query),
+		QueryStart:      sql.NullTime{Time: s.now, Valid: true},
+		State:           valid("active"),
+	}
+}
+
+func valid(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: true}
+}
+
+// waiting returns the session waiting for a lock in the phases waiting for other transactions.
+func waiting(a pgsp.Activity, phase string) pgsp.Activity {
+	if strings.HasPrefix(phase, "waiting for") {
+		a.WaitEventType, a.WaitEvent = valid("Lock"), valid("virtualxid")
+	}
+	return a
+}
+
+// newVacuum returns VACUUM of an autovacuum worker or a client
+// with the parallel index vacuuming of PostgreSQL 17.
+func newVacuum(s *Simulator) *job {
+	rel := s.relation()
+	blocks := int64(s.between(20000, 600000))
+	indexes := int64(1 + s.rng.Intn(6))
+	scan := s.between(20, 90)
+	a := s.client(fmt.Sprintf("VACUUM (VERBOSE, PARALLEL %d) %s;", indexes, rel.name))
+	if s.rng.Intn(2) == 0 {
+		a = pgsp.Activity{
+			BackendType: valid("autovacuum worker"),
+			Query:       valid("autovacuum: VACUUM " + rel.name),
+			QueryStart:  sql.NullTime{Time: s.now, Valid: true},
+			State:       valid("active"),
+		}
+	}
+	const maxDeadTupleBytes = 64 << 20
+	pid := s.pid()
+	return &job{
+		pid: pid,
+		stages: []stage{
+			{phase: "initializing", seconds: 1},
+			{phase: "scanning heap", total: blocks, seconds: scan},
+			{phase: "vacuuming indexes", total: indexes, seconds: s.between(5, 20)},
+			{phase: "vacuuming heap", total: blocks, seconds: scan / 3},
+			{phase: "cleaning up indexes", total: indexes, seconds: s.between(2, 5)},
+			{phase: "truncating heap", seconds: 1},
+			{phase: "performing final cleanup", seconds: 1},
+		},
+		row: func(j *job) pgsp.Progress {
+			phase := j.stage().phase
+			scanned := j.progress("scanning heap")
+			v := pgsp.Vacuum{
+				PID:               pid,
+				DATID:             rel.datid,
+				DATNAME:           rel.datname,
+				RELID:             rel.relid,
+				RelName:           rel.name,
+				PHASE:             phase,
+				HeapBLKSTotal:     blocks,
+				HeapBLKSScanned:   scanned,
+				HeapBLKSVacuumed:  j.progress("vacuuming heap"),
+				MaxDeadTupleBytes: maxDeadTupleBytes,
+				DeadTupleBytes:    scanned * 48 % maxDeadTupleBytes,
+				NumDeadItemIDs:    scanned * 8,
+				IndexesTotal:      indexes,
+				Activity:          a,
+			}
+			if phase == "vacuuming indexes" || phase == "cleaning up indexes" {
+				v.IndexesProcessed = j.done()
+			}
+			if j.progress("vacuuming indexes") == indexes {
+				v.IndexVacuumCount = 1
+			}
+			return v
+		},
+	}
+}
+
+// newAnalyze returns ANALYZE of a table or of a partitioned table with its partitions.
+func newAnalyze(s *Simulator) *job {
+	rel := s.relation()
+	extStats := int64(s.rng.Intn(3))
+	const sampleBlocks = 30000
+	stages := []stage{{phase: "initializing", seconds: 1}}
+	var children int
+	if s.rng.Intn(3) == 0 {
+		rel = partitioned
+		children = 4 + s.rng.Intn(9)
+		for i := 0; i < children; i++ {
+			stages = append(stages, stage{phase: "acquiring inherited sample rows", total: sampleBlocks, seconds: s.between(3, 8), part: i})
+		}
+	} else {
+		stages = append(stages, stage{phase: "acquiring sample rows", total: sampleBlocks, seconds: s.between(10, 40)})
+	}
+	stages = append(stages, stage{phase: "computing statistics", seconds: s.between(2, 6)})
+	if extStats > 0 {
+		stages = append(stages, stage{phase: "computing extended statistics", total: extStats, seconds: s.between(2, 6)})
+	}
+	stages = append(stages, stage{phase: "finalizing analyze", seconds: 1})
+
+	a := s.client("ANALYZE VERBOSE " + rel.name + ";")
+	pid := s.pid()
+	return &job{
+		pid:    pid,
+		stages: stages,
+		row: func(j *job) pgsp.Progress {
+			st := j.stage()
+			v := pgsp.Analyze{
+				PID:              pid,
+				DATID:            rel.datid,
+				DATNAME:          rel.datname,
+				RELID:            rel.relid,
+				RelName:          rel.name,
+				PHASE:            st.phase,
+				SampleBLKSTotal:  sampleBlocks,
+				ExtStatsTotal:    extStats,
+				ExtStatsComputed: j.progress("computing extended statistics"),
+				Activity:         a,
+			}
+			switch st.phase {
+			case "initializing":
+				v.SampleBLKSTotal = 0
+			case "acquiring sample rows":
+				v.SampleBLKSScanned = j.done()
+			case "acquiring inherited sample rows":
+				v.SampleBLKSScanned = j.done()
+				v.CurrentChildTableRelid = rel.relid + 1 + st.part
+				v.CurrentChildTableName = partitionName(st.part)
+			default:
+				v.SampleBLKSScanned = sampleBlocks
+			}
+			if children > 0 {
+				v.ChildTablesTotal = int64(children)
+				v.ChildTablesDone = int64(st.part)
+				if j.index > children {
+					v.ChildTablesDone = int64(children)
+				}
+			}
+			return v
+		},
+	}
+}
+
+// newCreateIndex returns CREATE INDEX [CONCURRENTLY], or CREATE INDEX of a partitioned table
+// that repeats the phases for each partition.
+func newCreateIndex(s *Simulator) *job {
+	rel := s.relation()
+	concurrently := s.rng.Intn(2) == 0
+	partitions := 0
+	if !concurrently && s.rng.Intn(3) == 0 {
+		rel = partitioned
+		partitions = 3 + s.rng.Intn(6)
+	}
+	command := "CREATE INDEX"
+	if concurrently {
+		command += " CONCURRENTLY"
+	}
+	blocks := int64(s.between(10000, 300000))
+	tuples := blocks * 60
+	lockers := int64(1 + s.rng.Intn(3))
+	build := func(part int, scale float64) []stage {
+		return []stage{
+			{phase: "building index: scanning table", total: blocks, seconds: s.between(10, 40) * scale, part: part},
+			{phase: "building index: sorting live tuples", seconds: s.between(3, 10) * scale, part: part},
+			{phase: "building index: loading tuples in tree", total: tuples, seconds: s.between(5, 20) * scale, part: part},
+		}
+	}
+	stages := []stage{{phase: "initializing", seconds: 1}}
+	switch {
+	case concurrently:
+		stages = append(stages, stage{phase: "waiting for writers before build", total: lockers, seconds: s.between(2, 15)})
+		stages = append(stages, build(0, 1)...)
+		stages = append(stages,
+			stage{phase: "waiting for writers before validation", total: lockers, seconds: s.between(2, 10)},
+			stage{phase: "index validation: scanning index", total: blocks / 4, seconds: s.between(3, 10)},
+			stage{phase: "index validation: sorting tuples", seconds: s.between(2, 6)},
+			stage{phase: "index validation: scanning table", total: blocks, seconds: s.between(10, 30)},
+			stage{phase: "waiting for old snapshots", total: lockers, seconds: s.between(2, 20)},
+		)
+	case partitions > 0:
+		for i := 0; i < partitions; i++ {
+			stages = append(stages, build(i, 0.3)...)
+		}
+	default:
+		stages = append(stages, build(0, 1)...)
+	}
+
+	schema, table, _ := strings.Cut(rel.name, ".")
+	index := table + "_created_at_idx"
+	a := s.client(fmt.Sprintf("%s %s ON %s (created_at);", command, index, rel.name))
+	pid := s.pid()
+	blocker := s.pid()
+	return &job{
+		pid:    pid,
+		stages: stages,
+		row: func(j *job) pgsp.Progress {
+			st := j.stage()
+			v := pgsp.CreateIndex{
+				PID:        pid,
+				DATID:      rel.datid,
+				DATNAME:    rel.datname,
+				RELID:      rel.relid,
+				RelName:    rel.name,
+				IndexRelid: rel.relid + 100,
+				IndexName:  schema + "." + index,
+				Command:    command,
+				PHASE:      st.phase,
+				Activity:   waiting(a, st.phase),
+			}
+			switch st.phase {
+			case "waiting for writers before build", "waiting for writers before validation", "waiting for old snapshots":
+				v.LockersTotal, v.LockersDone = lockers, j.done()
+				v.LockersPid = int64(blocker)
+			case "building index: scanning table", "index validation: scanning table":
+				v.BlocksTotal, v.BlocksDone = blocks, j.done()
+			case "index validation: scanning index":
+				v.BlocksTotal, v.BlocksDone = blocks/4, j.done()
+			case "building index: loading tuples in tree":
+				v.TuplesTotal, v.TuplesDone = tuples, j.done()
+			}
+			if partitions > 0 {
+				v.PartitionsTotal = int64(partitions)
+				v.PartitionsDone = int64(st.part)
+			}
+			return v
+		},
+	}
+}
+
+// newCluster returns CLUSTER with a sequential or an index scan, or VACUUM FULL.
+func newCluster(s *Simulator) *job {
+	rel := s.relation()
+	blocks := int64(s.between(10000, 200000))
+	tuples := blocks * 60
+	indexes := int64(1 + s.rng.Intn(4))
+	cluster := s.rng.Intn(2) == 0
+	indexScan := cluster && s.rng.Intn(2) == 0
+	command := "VACUUM FULL"
+	stages := []stage{
+		{phase: "initializing", seconds: 1},
+	}
+	switch {
+	case indexScan:
+		command = "CLUSTER"
+		// The index scan writes the new heap while scanning.
+		stages = append(stages, stage{phase: "index scanning heap", total: tuples, seconds: s.between(30, 90)})
+	case cluster:
+		command = "CLUSTER"
+		stages = append(stages,
+			stage{phase: "seq scanning heap", total: blocks, seconds: s.between(15, 60)},
+			stage{phase: "sorting tuples", seconds: s.between(5, 15)},
+			stage{phase: "writing new heap", total: tuples, seconds: s.between(10, 30)},
+		)
+	default:
+		stages = append(stages, stage{phase: "seq scanning heap", total: blocks, seconds: s.between(15, 60)})
+	}
+	stages = append(stages,
+		stage{phase: "swapping relation files", seconds: 1},
+		stage{phase: "rebuilding index", total: indexes, seconds: s.between(5, 20)},
+		stage{phase: "performing final cleanup", seconds: 1},
+	)
+	query := "VACUUM FULL " + rel.name + ";"
+	if cluster {
+		query = "CLUSTER " + rel.name + " USING " + rel.name + "_pkey;"
+	}
+	a := s.client(query)
+	pid := s.pid()
+	return &job{
+		pid:    pid,
+		stages: stages,
+		row: func(j *job) pgsp.Progress {
+			v := pgsp.Cluster{
+				PID:               pid,
+				DATID:             rel.datid,
+				DATNAME:           rel.datname,
+				RELID:             rel.relid,
+				RelName:           rel.name,
+				Command:           command,
+				PHASE:             j.stage().phase,
+				IndexRebuildCount: j.progress("rebuilding index"),
+				Activity:          a,
+			}
+			if indexScan {
+				v.HeapTuplesScanned = j.progress("index scanning heap")
+				v.HeapTuplesWritten = v.HeapTuplesScanned
+			} else {
+				scanned := j.progress("seq scanning heap")
+				v.HeapBlksTotal = blocks
+				v.HeapBlksScanned = scanned
+				v.HeapTuplesScanned = tuples * scanned / blocks
+				v.HeapTuplesWritten = j.progress("writing new heap")
+			}
+			if cluster {
+				v.ClusterIndexRelid = int64(rel.relid + 1)
+				v.ClusterIndexName = rel.name + "_pkey"
+			} else {
+				// VACUUM FULL writes the new heap while scanning.
+				v.HeapTuplesWritten = v.HeapTuplesScanned
+			}
+			return v
+		},
+	}
+}
+
+// newBaseBackup returns pg_basebackup, without the estimated size at times (--no-estimate-size).
+func newBaseBackup(s *Simulator) *job {
+	size := int64(s.between(1, 20) * (1 << 30))
+	tablespaces := int64(1 + s.rng.Intn(3))
+	estimate := s.rng.Intn(4) != 0
+	stages := []stage{
+		{phase: "initializing", seconds: 1},
+		{phase: "waiting for checkpoint to finish", seconds: s.between(3, 15)},
+		{phase: "estimating backup size", seconds: 1},
+		{phase: "streaming database files", total: size, seconds: s.between(60, 180)},
+		{phase: "waiting for wal archiving to finish", seconds: s.between(1, 5)},
+		{phase: "transferring wal files", seconds: s.between(1, 5)},
+	}
+	a := pgsp.Activity{
+		Usename:         valid("replicator"),
+		ApplicationName: valid("pg_basebackup"),
+		ClientAddr:      valid(fmt.Sprintf("10.0.1.%d", 2+s.rng.Intn(200))),
+		BackendType:     valid("walsender"),
+		Query:           valid("BASE_BACKUP ( LABEL 'pg_basebackup base backup',  PROGRESS,  CHECKPOINT 'fast',  WAIT 0,  MANIFEST 'yes',  TARGET 'client')"),
+		QueryStart:      sql.NullTime{Time: s.now, Valid: true},
+		State:           valid("active"),
+	}
+	pid := s.pid()
+	return &job{
+		pid:    pid,
+		stages: stages,
+		row: func(j *job) pgsp.Progress {
+			streamed := j.progress("streaming database files")
+			v := pgsp.BaseBackup{
+				PID:                 pid,
+				PHASE:               j.stage().phase,
+				BackupStreamed:      streamed,
+				TablespacesTotal:    tablespaces,
+				TablespacesStreamed: tablespaces * streamed / size,
+				Activity:            a,
+			}
+			if estimate && j.index > 2 {
+				v.BackupTotal = sql.NullInt64{Int64: size, Valid: true}
+			}
+			if v.PHASE == "waiting for checkpoint to finish" {
+				v.WaitEventType, v.WaitEvent = valid("Timeout"), valid("CheckpointWriteDelay")
+			}
+			return v
+		},
+	}
+}
+
+// newCopy returns COPY FROM a file with the known size,
+// or COPY FROM STDIN and COPY TO whose total is unknown.
+func newCopy(s *Simulator) *job {
+	rel := s.relation()
+	size := int64(s.between(100, 4000) * (1 << 20))
+	const rowBytes = 120
+	var command, typ, query string
+	known := false
+	switch s.rng.Intn(3) {
+	case 0:
+		command, typ, known = "COPY FROM", "FILE", true
+		query = fmt.Sprintf("COPY %s FROM '/data/import/%s.csv' WITH (FORMAT csv);", rel.name, rel.name)
+	case 1:
+		command, typ = "COPY FROM", "PIPE"
+		query = fmt.Sprintf("COPY %s FROM STDIN WITH (FORMAT csv);", rel.name)
+	default:
+		command, typ = "COPY TO", "PIPE"
+		query = fmt.Sprintf("COPY %s TO STDOUT WITH (FORMAT csv);", rel.name)
+	}
+	a := s.client(query)
+	if typ == "PIPE" && s.rng.Intn(2) == 0 {
+		a.WaitEventType, a.WaitEvent = valid("Client"), valid("ClientRead")
+	}
+	pid := s.pid()
+	return &job{
+		pid:    pid,
+		stages: []stage{{phase: command, total: size, seconds: s.between(20, 120)}},
+		row: func(j *job) pgsp.Progress {
+			done := j.done()
+			v := pgsp.Copy{
+				PID:             pid,
+				DATID:           rel.datid,
+				DATNAME:         rel.datname,
+				RELID:           rel.relid,
+				RelName:         rel.name,
+				COMMAND:         command,
+				CTYPE:           typ,
+				BYTESProcessed:  done,
+				TUPLESProcessed: done / rowBytes,
+				Activity:        a,
+			}
+			if known {
+				v.BYTESTotal = size
+			}
+			return v
+		},
+	}
+}
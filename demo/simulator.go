@@ -0,0 +1,181 @@
+// Package demo simulates a PostgreSQL server running maintenance commands
+// for demos and the development of the user interface without a server.
+package demo
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+// Default settings of Simulator.
+var (
+	DefaultStep        = 500 * time.Millisecond
+	DefaultConcurrency = 4
+	DefaultFailureRate = 0.15
+)
+
+// Simulator is a pgsp.Collector that generates evolving rows of all the views.
+// The rows are determined by the seed and the number of polls, not by the wall clock,
+// so that the same seed reproduces the same session.
+type Simulator struct {
+	// Step is the simulated time that passes between polls.
+	Step time.Duration
+	// Concurrency is the maximum number of the commands running at the same time.
+	Concurrency int
+	// FailureRate is the probability that a command fails or is cancelled.
+	FailureRate float64
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	now     time.Time
+	polled  bool
+	targets []pgsp.SPTaget
+	nextPid int
+	jobs    []*job
+	// outcomes is the outcomes of the finished commands.
+	outcomes map[outcomeKey]pgsp.Outcome
+}
+
+type outcomeKey struct {
+	name string
+	pid  int
+}
+
+// generators generates the commands of each target.
+var generators = map[pgsp.SPTaget]func(s *Simulator) *job{
+	pgsp.SPVacuum:      newVacuum,
+	pgsp.SPAnalyze:     newAnalyze,
+	pgsp.SPCreateIndex: newCreateIndex,
+	pgsp.SPCluster:     newCluster,
+	pgsp.SPBaseBackup:  newBaseBackup,
+	pgsp.SPCopy:        newCopy,
+}
+
+// New returns a Simulator of the seed whose clock starts at start.
+func New(seed int64, start time.Time) *Simulator {
+	s := &Simulator{
+		Step:        DefaultStep,
+		Concurrency: DefaultConcurrency,
+		FailureRate: DefaultFailureRate,
+		rng:         rand.New(rand.NewSource(seed)),
+		now:         start,
+		nextPid:     10000,
+		outcomes:    make(map[outcomeKey]pgsp.Outcome),
+	}
+	s.Targets(nil)
+	return s
+}
+
+// Targets enables the targets like pgsp.Pgsp.Targets.
+// All targets are enabled if none of the targets is valid.
+func (s *Simulator) Targets(target []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = nil
+	for _, t := range target {
+		name, _ := pgsp.LookupTarget(t)
+		if _, ok := generators[name]; ok {
+			s.targets = append(s.targets, name)
+		}
+	}
+	if len(s.targets) == 0 {
+		for t := range generators {
+			s.targets = append(s.targets, t)
+		}
+	}
+	sort.Slice(s.targets, func(i, j int) bool { return s.targets[i] < s.targets[j] })
+}
+
+// Poll advances the clock by Step and returns the rows of the running commands.
+func (s *Simulator) Poll(ctx context.Context) pgsp.Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.polled {
+		s.now = s.now.Add(s.Step)
+		s.advance(s.Step.Seconds())
+	}
+	s.polled = true
+	s.spawn()
+
+	result := pgsp.Result{
+		Time:     s.now,
+		Progress: make(map[pgsp.SPTaget][]pgsp.Progress, len(s.targets)),
+	}
+	for _, t := range s.targets {
+		result.Progress[t] = nil
+	}
+	for _, j := range s.jobs {
+		result.Progress[j.target] = append(result.Progress[j.target], j.row(j))
+	}
+	return result
+}
+
+// advance advances the commands and removes the finished ones.
+func (s *Simulator) advance(seconds float64) {
+	jobs := s.jobs[:0]
+	for _, j := range s.jobs {
+		if outcome, finished := j.advance(seconds); finished {
+			s.outcomes[outcomeKey{name: j.name, pid: j.pid}] = outcome
+			continue
+		}
+		jobs = append(jobs, j)
+	}
+	s.jobs = jobs
+}
+
+// spawn starts a command at times while fewer than Concurrency are running.
+func (s *Simulator) spawn() {
+	if len(s.jobs) >= s.Concurrency {
+		return
+	}
+	if len(s.jobs) > 0 && s.rng.Float64() > 0.1 {
+		return
+	}
+	target := s.targets[s.rng.Intn(len(s.targets))]
+	j := generators[target](s)
+	j.target = target
+	j.name = j.row(j).Name()
+	j.failAt = -1
+	if s.rng.Float64() < s.FailureRate {
+		j.failAt = s.rng.Intn(len(j.stages))
+		j.failure = pgsp.OutcomeFailed
+		if s.rng.Intn(2) == 0 {
+			j.failure = pgsp.OutcomeCancelled
+		}
+	}
+	s.jobs = append(s.jobs, j)
+}
+
+// pid returns the pid of a new backend.
+func (s *Simulator) pid() int {
+	s.nextPid += 1 + s.rng.Intn(50)
+	return s.nextPid
+}
+
+// Outcome returns the outcome of the command that finished.
+func (s *Simulator) Outcome(ctx context.Context, v pgsp.Progress, vanished time.Time) pgsp.Outcome {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outcomes[outcomeKey{name: v.Name(), pid: v.Pid()}]
+}
+
+func (s *Simulator) TargetString() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := make([]string, len(s.targets))
+	for i, t := range s.targets {
+		ts[i] = string(t)
+	}
+	return strings.Join(ts, " ")
+}
+
+// TargetStatus returns an empty string because all targets are polled normally.
+func (s *Simulator) TargetStatus(now time.Time) string {
+	return ""
+}
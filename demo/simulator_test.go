@@ -0,0 +1,101 @@
+package demo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+func TestSimulator_Poll(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(1, start)
+	s.Concurrency = 6
+	s.FailureRate = 0.3
+
+	seen := make(map[pgsp.SPTaget]bool)
+	for i := 0; i < 5000; i++ {
+		result := s.Poll(ctx)
+		if want := start.Add(time.Duration(i) * s.Step); !result.Time.Equal(want) {
+			t.Fatalf("Simulator.Poll() time = %s, want %s", result.Time, want)
+		}
+		for target, vs := range result.Progress {
+			for _, v := range vs {
+				seen[target] = true
+				st := v.Status()
+				if target != pgsp.SPCopy && st.Index < 0 {
+					t.Errorf("Simulator.Poll() %s has unknown phase %q", target, st.Phase)
+				}
+				if st.Overall < 0 || st.Overall > 1 {
+					t.Errorf("Simulator.Poll() %s progress = %v", target, st.Overall)
+				}
+				if c := v.Counter(); c.Total > 0 && c.Done > c.Total {
+					t.Errorf("Simulator.Poll() %s counter = %v", target, c)
+				}
+			}
+		}
+	}
+	for target := range generators {
+		if !seen[target] {
+			t.Errorf("Simulator.Poll() did not generate %s", target)
+		}
+	}
+	outcomes := make(map[pgsp.Outcome]int)
+	for _, o := range s.outcomes {
+		outcomes[o]++
+	}
+	for _, o := range []pgsp.Outcome{pgsp.OutcomeCompleted, pgsp.OutcomeFailed, pgsp.OutcomeCancelled} {
+		if outcomes[o] == 0 {
+			t.Errorf("Simulator.Poll() had no %s command", o)
+		}
+	}
+}
+
+func TestSimulator_seed(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := func(seed int64) []map[string]interface{} {
+		s := New(seed, start)
+		var rows []map[string]interface{}
+		for i := 0; i < 200; i++ {
+			result := s.Poll(ctx)
+			for _, target := range pgsp.Registered() {
+				for _, v := range result.Progress[target] {
+					rows = append(rows, pgsp.RowOf(v))
+				}
+			}
+		}
+		return rows
+	}
+	if a, b := rows(42), rows(42); !reflect.DeepEqual(a, b) {
+		t.Errorf("Simulator with the same seed generated different rows")
+	}
+	if a, b := rows(42), rows(43); reflect.DeepEqual(a, b) {
+		t.Errorf("Simulator with different seeds generated the same rows")
+	}
+}
+
+func TestSimulator_Outcome(t *testing.T) {
+	ctx := context.Background()
+	s := New(1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := pgsp.NewWatcher(s, s.Step)
+	finished := 0
+	for i := 0; i < 2000; i++ {
+		for _, e := range w.Poll(ctx).Events {
+			f, ok := e.(*pgsp.OperationFinished)
+			if !ok {
+				continue
+			}
+			finished++
+			if f.Outcome == pgsp.OutcomeUnknown {
+				t.Errorf("Watcher with Simulator finished %s %d with unknown outcome", f.Operation.Progress.Name(), f.Operation.Progress.Pid())
+			}
+		}
+	}
+	if finished == 0 {
+		t.Errorf("Watcher with Simulator finished no operation")
+	}
+}
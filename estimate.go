@@ -0,0 +1,132 @@
+package pgsp
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Counter is the amount of work of the current phase of the progress.
+type Counter struct {
+	Done  int64
+	Total int64
+	Unit  string
+}
+
+// Units of Counter.
+const (
+	UnitBlocks      = "blocks"
+	UnitBytes       = "bytes"
+	UnitTuples      = "tuples"
+	UnitTables      = "tables"
+	UnitPartitions  = "partitions"
+	UnitStats       = "stats"
+	UnitTablespaces = "tablespaces"
+	UnitIndexes     = "indexes"
+)
+
+// SmoothingTime is the time constant of the exponential moving average of the rate.
+var SmoothingTime = 10 * time.Second
+
+// Estimator estimates the rate and the remaining time of a progress
+// from successive snapshots of the same operation.
+// It is reset when the phase or the unit of the counter changes.
+type Estimator struct {
+	phase string
+	unit  string
+	done  int64
+	time  time.Time
+	rate  float64
+	valid bool
+}
+
+// Update adds a snapshot of the progress at t.
+func (e *Estimator) Update(t time.Time, phase string, c Counter) {
+	if e.time.IsZero() || phase != e.phase || c.Unit != e.unit || c.Done < e.done {
+		e.reset(t, phase, c)
+		return
+	}
+	dt := t.Sub(e.time).Seconds()
+	if dt <= 0 {
+		return
+	}
+	rate := float64(c.Done-e.done) / dt
+	if !e.valid {
+		e.rate = rate
+		e.valid = true
+	} else {
+		alpha := 1 - math.Exp(-dt/SmoothingTime.Seconds())
+		e.rate += alpha * (rate - e.rate)
+	}
+	e.done = c.Done
+	e.time = t
+}
+
+func (e *Estimator) reset(t time.Time, phase string, c Counter) {
+	e.phase = phase
+	e.unit = c.Unit
+	e.done = c.Done
+	e.time = t
+	e.rate = 0
+	e.valid = false
+}
+
+// Rate returns the smoothed rate in units per second.
+// Returns false if it has not been estimated yet.
+func (e *Estimator) Rate() (float64, bool) {
+	return e.rate, e.valid
+}
+
+// PhaseETA returns the estimated time remaining of the counter of the current phase.
+// It does not include the later phases.
+// Returns false if it cannot be estimated.
+func (e *Estimator) PhaseETA(c Counter) (time.Duration, bool) {
+	if !e.valid || e.rate <= 0 || c.Total <= 0 || c.Unit != e.unit {
+		return 0, false
+	}
+	remain := float64(c.Total - c.Done)
+	if remain < 0 {
+		remain = 0
+	}
+	return time.Duration(remain / e.rate * float64(time.Second)), true
+}
+
+// String returns the rate and the ETA of the phase as "rate/s phase ETA duration".
+func (e *Estimator) String(c Counter) string {
+	rate, hasRate := e.Rate()
+	eta, hasETA := e.PhaseETA(c)
+	return formatEstimate(rate, hasRate, eta, hasETA, c.Unit)
+}
+
+func formatEstimate(rate float64, hasRate bool, eta time.Duration, hasETA bool, unit string) string {
+	if !hasRate || unit == "" {
+		return ""
+	}
+	s := FormatRate(rate, unit)
+	if hasETA {
+		s += " phase ETA " + eta.Round(time.Second).String()
+	}
+	return s
+}
+
+// FormatRate returns a human readable rate.
+func FormatRate(rate float64, unit string) string {
+	if unit == UnitBytes {
+		return FormatBytes(rate) + "/s"
+	}
+	return fmt.Sprintf("%.1f %s/s", rate, unit)
+}
+
+// FormatBytes returns a human readable size.
+func FormatBytes(b float64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%.0f B", b)
+	}
+	div, exp := float64(unit), 0
+	for n := b / unit; n >= unit && exp < 5; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", b/div, "KMGTPE"[exp])
+}
@@ -0,0 +1,112 @@
+package pgsp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimator_Update(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	type snapshot struct {
+		after time.Duration
+		phase string
+		c     Counter
+	}
+	tests := []struct {
+		name      string
+		snapshots []snapshot
+		wantRate  float64
+		wantValid bool
+		wantETA   time.Duration
+	}{
+		{
+			name: "first",
+			snapshots: []snapshot{
+				{0, "scanning heap", Counter{Done: 0, Total: 1000, Unit: UnitBlocks}},
+			},
+			wantValid: false,
+		},
+		{
+			name: "constant",
+			snapshots: []snapshot{
+				{0, "scanning heap", Counter{Done: 0, Total: 1000, Unit: UnitBlocks}},
+				{time.Second, "scanning heap", Counter{Done: 100, Total: 1000, Unit: UnitBlocks}},
+				{2 * time.Second, "scanning heap", Counter{Done: 200, Total: 1000, Unit: UnitBlocks}},
+			},
+			wantRate:  100,
+			wantValid: true,
+			wantETA:   8 * time.Second,
+		},
+		{
+			name: "phaseChanged",
+			snapshots: []snapshot{
+				{0, "building index: scanning table", Counter{Done: 0, Total: 1000, Unit: UnitBlocks}},
+				{time.Second, "building index: scanning table", Counter{Done: 1000, Total: 1000, Unit: UnitBlocks}},
+				{2 * time.Second, "building index: sorting live tuples", Counter{Done: 0, Total: 0, Unit: UnitTuples}},
+			},
+			wantValid: false,
+		},
+		{
+			name: "afterPhaseChanged",
+			snapshots: []snapshot{
+				{0, "building index: scanning table", Counter{Done: 0, Total: 1000, Unit: UnitBlocks}},
+				{time.Second, "building index: scanning table", Counter{Done: 1000, Total: 1000, Unit: UnitBlocks}},
+				{2 * time.Second, "building index: loading tuples in tree", Counter{Done: 0, Total: 500, Unit: UnitTuples}},
+				{3 * time.Second, "building index: loading tuples in tree", Counter{Done: 50, Total: 500, Unit: UnitTuples}},
+			},
+			wantRate:  50,
+			wantValid: true,
+			wantETA:   9 * time.Second,
+		},
+		{
+			name: "decreased",
+			snapshots: []snapshot{
+				{0, "scanning heap", Counter{Done: 500, Total: 1000, Unit: UnitBlocks}},
+				{time.Second, "scanning heap", Counter{Done: 10, Total: 1000, Unit: UnitBlocks}},
+			},
+			wantValid: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Estimator{}
+			var last Counter
+			for _, s := range tt.snapshots {
+				e.Update(start.Add(s.after), s.phase, s.c)
+				last = s.c
+			}
+			rate, valid := e.Rate()
+			if valid != tt.wantValid {
+				t.Fatalf("Estimator.Rate() valid = %v, want %v", valid, tt.wantValid)
+			}
+			if !valid {
+				return
+			}
+			if rate < tt.wantRate-0.001 || rate > tt.wantRate+0.001 {
+				t.Errorf("Estimator.Rate() = %v, want %v", rate, tt.wantRate)
+			}
+			eta, ok := e.PhaseETA(last)
+			if !ok || eta != tt.wantETA {
+				t.Errorf("Estimator.PhaseETA() = %v, want %v", eta, tt.wantETA)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		b    float64
+		want string
+	}{
+		{b: 512, want: "512 B"},
+		{b: 1536, want: "1.5 KiB"},
+		{b: 10 * 1024 * 1024, want: "10.0 MiB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatBytes(tt.b); got != tt.want {
+				t.Errorf("FormatBytes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
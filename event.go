@@ -0,0 +1,103 @@
+package pgsp
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event is a lifecycle event of an operation emitted by Watcher.
+// It is one of *OperationStarted, *PhaseChanged, *OperationProgressed,
+// *OperationFinished and *Stalled.
+type Event interface {
+	// Op returns the operation at the time of the event.
+	Op() Operation
+	// When returns the time of the event.
+	When() time.Time
+	String() string
+}
+
+// EventInfo is the common part of the events.
+type EventInfo struct {
+	Operation Operation
+	Time      time.Time
+}
+
+func (e EventInfo) Op() Operation {
+	return e.Operation
+}
+
+func (e EventInfo) When() time.Time {
+	return e.Time
+}
+
+func (e EventInfo) describe() string {
+	v := e.Operation.Progress
+	s := fmt.Sprintf("%s pid=%d", v.Name(), v.Pid())
+	if rel := v.Relation(); rel != "" {
+		s += " relation=" + rel
+	}
+	return s
+}
+
+// OperationStarted is emitted when an operation appears.
+type OperationStarted struct {
+	EventInfo
+}
+
+func (e *OperationStarted) String() string {
+	return "started " + e.describe()
+}
+
+// PhaseChanged is emitted when the phase of an operation changes.
+type PhaseChanged struct {
+	EventInfo
+	From string
+	To   string
+}
+
+func (e *PhaseChanged) String() string {
+	return fmt.Sprintf("phase changed %s from=%q to=%q", e.describe(), e.From, e.To)
+}
+
+// OperationProgressed is emitted when the overall completion crosses a ProgressStep.
+type OperationProgressed struct {
+	EventInfo
+	Progress float64
+}
+
+func (e *OperationProgressed) String() string {
+	return fmt.Sprintf("progressed %s %.0f%%", e.describe(), e.Progress*100)
+}
+
+// OperationFinished is emitted when the outcome of an operation that disappeared is determined,
+// or with OutcomeUnknown when it cannot be determined within the retention.
+type OperationFinished struct {
+	EventInfo
+	Duration time.Duration
+	Outcome  Outcome
+}
+
+func (e *OperationFinished) String() string {
+	return fmt.Sprintf("finished %s outcome=%s duration=%s", e.describe(), e.Outcome, e.Duration.Round(time.Second))
+}
+
+// Stalled is emitted when an operation makes no progress for StallTimeout.
+// Phases that report no counter are not considered stalled.
+// It is emitted again after the operation progresses and stalls again.
+type Stalled struct {
+	EventInfo
+	// Since is the time of the last progress.
+	Since time.Time
+}
+
+func (e *Stalled) String() string {
+	return fmt.Sprintf("stalled %s phase=%q for=%s", e.describe(), e.Operation.Progress.Phase(), e.Time.Sub(e.Since).Round(time.Second))
+}
+
+// Settings of the events.
+var (
+	// ProgressStep is the step of the overall completion that emits OperationProgressed.
+	ProgressStep = 0.1
+	// StallTimeout is the time without progress that emits Stalled.
+	StallTimeout = time.Minute
+)
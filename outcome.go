@@ -0,0 +1,219 @@
+package pgsp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Outcome is the result of an operation that disappeared from the view.
+type Outcome int
+
+const (
+	// OutcomeUnknown is not determined yet.
+	OutcomeUnknown Outcome = iota
+	OutcomeCompleted
+	OutcomeFailed
+	OutcomeCancelled
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeCompleted:
+		return "completed"
+	case OutcomeFailed:
+		return "failed"
+	case OutcomeCancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+// ParseOutcome returns the Outcome of the string returned by String.
+func ParseOutcome(s string) Outcome {
+	for _, o := range []Outcome{OutcomeCompleted, OutcomeFailed, OutcomeCancelled} {
+		if o.String() == s {
+			return o
+		}
+	}
+	return OutcomeUnknown
+}
+
+// OutcomeDelay is the time to wait for the statistics to be updated
+// before concluding that an operation did not complete.
+var OutcomeDelay = 2 * time.Second
+
+// Finisher is implemented by Progress that can find evidence of its outcome in the catalog.
+type Finisher interface {
+	// Finished returns OutcomeCompleted or OutcomeFailed if there is evidence,
+	// otherwise OutcomeUnknown.
+	// checked is false if the evidence cannot be looked for.
+	Finished(ctx context.Context, r *Resolver) (o Outcome, checked bool)
+}
+
+// backend states of pg_stat_activity.
+const (
+	stateIdleInTransaction = "idle in transaction"
+	stateAborted           = "idle in transaction (aborted)"
+)
+
+// Outcome determines the outcome of the operation v that disappeared from the view at vanished.
+// Returns OutcomeUnknown if it cannot be determined yet, and should be called again later.
+func (p *Pgsp) Outcome(ctx context.Context, v Progress, vanished time.Time) Outcome {
+	e := evidence{
+		waited: time.Since(vanished) >= OutcomeDelay,
+		final:  finalPhase(v.Status()),
+	}
+	if f, ok := v.(Finisher); ok {
+		e.found, e.checked = f.Finished(ctx, p.Resolver)
+		if e.found != OutcomeUnknown {
+			return e.found
+		}
+	}
+
+	var state sql.NullString
+	err := p.DB.GetContext(ctx, &state, "SELECT state FROM pg_stat_activity WHERE pid = $1", v.Pid())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return OutcomeUnknown
+	}
+	e.alive = err == nil
+	e.state = state.String
+	return e.outcome()
+}
+
+// Forget drops the relfilenode kept for the CLUSTER or VACUUM FULL v.
+func (p *Pgsp) Forget(v Progress) {
+	if c, ok := v.(Cluster); ok {
+		p.Resolver.ForgetFilenode(c.DATID, c.RELID, c.PID, c.QueryStart.Time)
+	}
+}
+
+// evidence is what is known about an operation that disappeared from the view.
+type evidence struct {
+	// found is the outcome found in the catalog by Finisher.
+	found Outcome
+	// checked is true if Finisher could look for the evidence in the catalog.
+	checked bool
+	// alive is true if the backend still exists, with the state of the backend.
+	alive bool
+	state string
+	// waited is true if OutcomeDelay has passed since the operation disappeared.
+	waited bool
+	// final is true if the operation was in the last phase or had done all the work.
+	final bool
+}
+
+// outcome decides the outcome by the evidence.
+func (e evidence) outcome() Outcome {
+	switch {
+	case e.found != OutcomeUnknown:
+		return e.found
+	case e.alive && e.state == stateAborted:
+		return OutcomeCancelled
+	case e.alive && e.state == stateIdleInTransaction:
+		// The result is not visible until the transaction ends.
+		return OutcomeUnknown
+	case e.checked && !e.waited:
+		// Wait for the statistics to be updated.
+		return OutcomeUnknown
+	case e.checked && e.alive:
+		// The statement ended without evidence of completion.
+		return OutcomeCancelled
+	case e.final:
+		// The operation ended after the last phase (e.g. walsender of BASE_BACKUP, COPY of all bytes).
+		return OutcomeCompleted
+	case e.alive:
+		// The statement ended, but there is no evidence of how.
+		return OutcomeUnknown
+	}
+	// The backend was terminated.
+	return OutcomeFailed
+}
+
+// finalPhase returns true if the status is in the last phase or complete.
+func finalPhase(s Status) bool {
+	if s.Index >= 0 {
+		return s.Index == len(s.Phases)-1
+	}
+	return !s.Indeterminate && s.PhaseProgress >= 1
+}
+
+// finishedAfter returns OutcomeCompleted if the statistics timestamp of the relation
+// has been updated after the query started.
+func finishedAfter(ctx context.Context, r *Resolver, datid int, query string, relid int, start sql.NullTime) (Outcome, bool) {
+	if !start.Valid || relid == 0 {
+		return OutcomeUnknown, false
+	}
+	db := r.DB(ctx, datid)
+	if db == nil {
+		return OutcomeUnknown, false
+	}
+	var done bool
+	if err := db.GetContext(ctx, &done, query, relid, start.Time); err != nil {
+		return OutcomeUnknown, false
+	}
+	if done {
+		return OutcomeCompleted, true
+	}
+	return OutcomeUnknown, true
+}
+
+const (
+	vacuumFinishedQuery = `SELECT coalesce(greatest(last_vacuum, last_autovacuum) >= $2, false)
+ FROM pg_stat_all_tables WHERE relid = $1`
+	analyzeFinishedQuery = `SELECT coalesce(greatest(last_analyze, last_autoanalyze) >= $2, false)
+ FROM pg_stat_all_tables WHERE relid = $1`
+	indexValidQuery = `SELECT indisvalid FROM pg_index WHERE indexrelid = $1`
+	filenodeQuery   = `SELECT relfilenode FROM pg_class WHERE oid = $1`
+)
+
+func (v Vacuum) Finished(ctx context.Context, r *Resolver) (Outcome, bool) {
+	return finishedAfter(ctx, r, v.DATID, vacuumFinishedQuery, v.RELID, v.QueryStart)
+}
+
+func (v Analyze) Finished(ctx context.Context, r *Resolver) (Outcome, bool) {
+	return finishedAfter(ctx, r, v.DATID, analyzeFinishedQuery, v.RELID, v.QueryStart)
+}
+
+// Finished checks the validity of the index built by CREATE INDEX CONCURRENTLY,
+// which leaves an invalid index when it fails.
+// The index of the other commands is no evidence: REINDEX keeps the old index valid
+// when it fails, and REINDEX CONCURRENTLY drops it when it completes.
+func (v CreateIndex) Finished(ctx context.Context, r *Resolver) (Outcome, bool) {
+	if v.Command != "CREATE INDEX CONCURRENTLY" || v.IndexRelid == 0 {
+		return OutcomeUnknown, false
+	}
+	db := r.DB(ctx, v.DATID)
+	if db == nil {
+		return OutcomeUnknown, false
+	}
+	var valid bool
+	if err := db.GetContext(ctx, &valid, indexValidQuery, v.IndexRelid); err != nil {
+		return OutcomeUnknown, false
+	}
+	if valid {
+		return OutcomeCompleted, true
+	}
+	return OutcomeFailed, true
+}
+
+// Finished checks whether the relation has been rewritten.
+// CLUSTER and VACUUM FULL swap the relfilenode of the relation when they complete.
+func (v Cluster) Finished(ctx context.Context, r *Resolver) (Outcome, bool) {
+	if v.Filenode == 0 {
+		return OutcomeUnknown, false
+	}
+	db := r.DB(ctx, v.DATID)
+	if db == nil {
+		return OutcomeUnknown, false
+	}
+	var filenode int64
+	if err := db.GetContext(ctx, &filenode, filenodeQuery, v.RELID); err != nil {
+		return OutcomeUnknown, false
+	}
+	if filenode != v.Filenode {
+		return OutcomeCompleted, true
+	}
+	return OutcomeUnknown, true
+}
@@ -0,0 +1,301 @@
+package pgsp
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeDriver answers a query with the value of the first key contained in the query,
+// or with no rows.
+type fakeDriver struct {
+	answers map[string]driver.Value
+}
+
+type fakeConn struct{ d *fakeDriver }
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+type fakeRows struct {
+	value driver.Value
+	found bool
+}
+
+var (
+	fakeMu      sync.Mutex
+	fakeDrivers int
+)
+
+// fakeDB returns the database that answers the queries by answers.
+func fakeDB(t *testing.T, answers map[string]driver.Value) *sqlx.DB {
+	t.Helper()
+	fakeMu.Lock()
+	fakeDrivers++
+	name := fmt.Sprintf("pgsp-fake-%d", fakeDrivers)
+	fakeMu.Unlock()
+	sql.Register(name, &fakeDriver{answers: answers})
+	db, err := sqlx.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d: d}, nil }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	for key, value := range s.d.answers {
+		if strings.Contains(s.query, key) {
+			return &fakeRows{value: value}, nil
+		}
+	}
+	return &fakeRows{found: true}, nil
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.found {
+		return io.EOF
+	}
+	r.found = true
+	dest[0] = r.value
+	return nil
+}
+
+func Test_finalPhase(t *testing.T) {
+	tests := []struct {
+		name string
+		v    Progress
+		want bool
+	}{
+		{
+			name: "basebackupWAL",
+			v:    BaseBackup{PHASE: "transferring wal files"},
+			want: true,
+		},
+		{
+			name: "basebackupStreaming",
+			v: BaseBackup{
+				PHASE:          "streaming database files",
+				BackupTotal:    sql.NullInt64{Int64: 100, Valid: true},
+				BackupStreamed: 50,
+			},
+			want: false,
+		},
+		{
+			name: "copyDone",
+			v:    Copy{COMMAND: "COPY TO", BYTESProcessed: 100, BYTESTotal: 100},
+			want: true,
+		},
+		{
+			name: "copyStdin",
+			v:    Copy{COMMAND: "COPY FROM", BYTESProcessed: 100},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := finalPhase(tt.v.Status()); got != tt.want {
+				t.Errorf("finalPhase() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_evidence_outcome(t *testing.T) {
+	tests := []struct {
+		name string
+		e    evidence
+		want Outcome
+	}{
+		{
+			name: "found",
+			e:    evidence{found: OutcomeFailed, checked: true, alive: true},
+			want: OutcomeFailed,
+		},
+		{
+			name: "aborted",
+			e:    evidence{alive: true, state: stateAborted, final: true},
+			want: OutcomeCancelled,
+		},
+		{
+			name: "inTransaction",
+			e:    evidence{checked: true, alive: true, state: stateIdleInTransaction, waited: true, final: true},
+			want: OutcomeUnknown,
+		},
+		{
+			name: "waitStatistics",
+			e:    evidence{checked: true, alive: true, state: "idle"},
+			want: OutcomeUnknown,
+		},
+		{
+			name: "checkedAlive",
+			e:    evidence{checked: true, alive: true, state: "idle", waited: true, final: true},
+			want: OutcomeCancelled,
+		},
+		{
+			name: "checkedTerminated",
+			e:    evidence{checked: true, waited: true},
+			want: OutcomeFailed,
+		},
+		{
+			name: "finalAlive",
+			e:    evidence{alive: true, state: "idle", final: true},
+			want: OutcomeCompleted,
+		},
+		{
+			name: "finalExited",
+			e:    evidence{final: true},
+			want: OutcomeCompleted,
+		},
+		{
+			name: "noEvidence",
+			e:    evidence{alive: true, state: "idle", waited: true},
+			want: OutcomeUnknown,
+		},
+		{
+			name: "terminated",
+			e:    evidence{waited: true},
+			want: OutcomeFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.outcome(); got != tt.want {
+				t.Errorf("evidence.outcome() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCluster_rewritten(t *testing.T) {
+	tests := []struct {
+		name string
+		v    Cluster
+		want bool
+	}{
+		{
+			name: "seqScan",
+			v:    Cluster{PHASE: "seq scanning heap", HeapBlksTotal: 100},
+			want: false,
+		},
+		{
+			name: "writing",
+			v:    Cluster{PHASE: "writing new heap", ClusterIndexRelid: 2, HeapBlksTotal: 100},
+			want: false,
+		},
+		{
+			name: "swapping",
+			v:    Cluster{PHASE: "swapping relation files", HeapBlksTotal: 100},
+			want: true,
+		},
+		{
+			name: "indexScanRebuilding",
+			v:    Cluster{PHASE: "rebuilding index", ClusterIndexRelid: 2},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.v.rewritten(); got != tt.want {
+				t.Errorf("Cluster.rewritten() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateIndex_Finished(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		valid       bool
+		want        Outcome
+		wantChecked bool
+	}{
+		{
+			name:        "concurrentlyValid",
+			command:     "CREATE INDEX CONCURRENTLY",
+			valid:       true,
+			want:        OutcomeCompleted,
+			wantChecked: true,
+		},
+		{
+			name:        "concurrentlyInvalid",
+			command:     "CREATE INDEX CONCURRENTLY",
+			valid:       false,
+			want:        OutcomeFailed,
+			wantChecked: true,
+		},
+		{
+			name:    "reindex",
+			command: "REINDEX",
+			valid:   true,
+			want:    OutcomeUnknown,
+		},
+		{
+			name:    "reindexConcurrently",
+			command: "REINDEX CONCURRENTLY",
+			valid:   true,
+			want:    OutcomeUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := fakeDB(t, map[string]driver.Value{
+				"current_database": int64(1),
+				"indisvalid":       tt.valid,
+			})
+			v := CreateIndex{DATID: 1, IndexRelid: 16390, Command: tt.command}
+			got, checked := v.Finished(context.Background(), NewResolver("", db))
+			if got != tt.want || checked != tt.wantChecked {
+				t.Errorf("CreateIndex.Finished() = %v, %v, want %v, %v", got, checked, tt.want, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func TestPgsp_Outcome_cancelledReindex(t *testing.T) {
+	db := fakeDB(t, map[string]driver.Value{
+		"current_database": int64(1),
+		// The index being rebuilt stays valid when REINDEX is cancelled.
+		"indisvalid":       true,
+		"pg_stat_activity": "idle",
+	})
+	p := &Pgsp{DB: db, Resolver: NewResolver("", db)}
+	v := CreateIndex{
+		PID:        10,
+		DATID:      1,
+		IndexRelid: 16390,
+		Command:    "REINDEX",
+		PHASE:      "building index: scanning table",
+		BlocksDone: 10, BlocksTotal: 100,
+	}
+	if got := p.Outcome(context.Background(), v, time.Now().Add(-time.Minute)); got == OutcomeCompleted {
+		t.Errorf("Pgsp.Outcome() of a cancelled REINDEX = %v", got)
+	}
+}
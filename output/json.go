@@ -0,0 +1,184 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+// Record is a line of the JSON Lines output.
+// Type is "snapshot" with Operations and Servers, or "event" with Event and Operation.
+type Record struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	Operations []OperationRecord `json:"operations,omitempty"`
+	Servers    []ServerRecord    `json:"servers,omitempty"`
+
+	Event     string           `json:"event,omitempty"`
+	Operation *OperationRecord `json:"operation,omitempty"`
+	// From and To are the phases of "phase_changed".
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Progress is the overall completion of "progressed".
+	Progress float64 `json:"progress,omitempty"`
+	// Duration and Outcome are of "finished".
+	Duration float64 `json:"duration_seconds,omitempty"`
+	Outcome  string  `json:"outcome,omitempty"`
+	// Since is the time of the last progress of "stalled".
+	Since *time.Time `json:"since,omitempty"`
+}
+
+// OperationRecord is an operation with the computed progress and the full row.
+type OperationRecord struct {
+	ID       uint64       `json:"id"`
+	Server   string       `json:"server,omitempty"`
+	Target   pgsp.SPTaget `json:"target"`
+	View     string       `json:"view"`
+	Pid      int          `json:"pid"`
+	Relation string       `json:"relation,omitempty"`
+	Phase    string       `json:"phase"`
+	// Percent is the overall completion (0 to 100) rounded to two decimal places.
+	Percent       float64 `json:"percent"`
+	Indeterminate bool    `json:"indeterminate,omitempty"`
+	// Done, Total and Unit are the counter of the current phase.
+	Done  int64  `json:"done"`
+	Total int64  `json:"total"`
+	Unit  string `json:"unit,omitempty"`
+	// Rate is the rate of the current phase in Unit per second.
+	Rate *float64 `json:"rate,omitempty"`
+	// PhaseETA is the estimated time remaining of the current phase in seconds.
+	PhaseETA *float64   `json:"phase_eta_seconds,omitempty"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Outcome  string     `json:"outcome,omitempty"`
+	// Columns is the columns of the view selected for the server version,
+	// empty if the row is not of a pg_stat_progress view.
+	Columns []string `json:"columns,omitempty"`
+	// Row is the columns of the view by the names of the columns.
+	Row map[string]interface{} `json:"row"`
+}
+
+// ServerRecord is the state of a server.
+type ServerRecord struct {
+	Name    string `json:"name,omitempty"`
+	Targets string `json:"targets"`
+	Status  string `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Attempt int    `json:"attempt,omitempty"`
+}
+
+// JSON prints a JSON object for each snapshot and each event (JSON Lines).
+type JSON struct {
+	enc *json.Encoder
+}
+
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{enc: json.NewEncoder(w)}
+}
+
+func (j *JSON) Print(s pgsp.Snapshot) error {
+	if err := j.enc.Encode(SnapshotRecord(s)); err != nil {
+		return err
+	}
+	for _, e := range s.Events {
+		if err := j.enc.Encode(EventRecord(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnapshotRecord returns the record of the snapshot without the events.
+func SnapshotRecord(s pgsp.Snapshot) Record {
+	r := Record{
+		Type:       "snapshot",
+		Time:       s.Time,
+		Operations: make([]OperationRecord, 0, len(s.Operations)),
+	}
+	for _, op := range s.Operations {
+		r.Operations = append(r.Operations, NewOperationRecord(op))
+	}
+	for _, srv := range s.Servers {
+		sr := ServerRecord{
+			Name:    srv.Name,
+			Targets: srv.Targets,
+			Status:  srv.Status,
+			Attempt: srv.Attempt,
+		}
+		if srv.Err != nil {
+			sr.Error = srv.Err.Error()
+		}
+		r.Servers = append(r.Servers, sr)
+	}
+	return r
+}
+
+// EventRecord returns the record of the event.
+func EventRecord(e pgsp.Event) Record {
+	op := NewOperationRecord(e.Op())
+	r := Record{
+		Type:      "event",
+		Time:      e.When(),
+		Operation: &op,
+	}
+	switch e := e.(type) {
+	case *pgsp.OperationStarted:
+		r.Event = "started"
+	case *pgsp.PhaseChanged:
+		r.Event = "phase_changed"
+		r.From, r.To = e.From, e.To
+	case *pgsp.OperationProgressed:
+		r.Event = "progressed"
+		r.Progress = e.Progress
+	case *pgsp.OperationFinished:
+		r.Event = "finished"
+		r.Duration = e.Duration.Seconds()
+		r.Outcome = e.Outcome.String()
+	case *pgsp.Stalled:
+		r.Event = "stalled"
+		since := e.Since
+		r.Since = &since
+	}
+	return r
+}
+
+// NewOperationRecord returns the record of the operation.
+func NewOperationRecord(op pgsp.Operation) OperationRecord {
+	v := op.Progress
+	st := v.Status()
+	r := OperationRecord{
+		ID:            op.ID,
+		Server:        op.Server,
+		Target:        op.Target,
+		View:          v.Name(),
+		Pid:           v.Pid(),
+		Relation:      v.Relation(),
+		Phase:         st.Phase,
+		Percent:       math.Round(st.Overall*10000) / 100,
+		Indeterminate: st.Indeterminate,
+		Done:          st.Counter.Done,
+		Total:         st.Counter.Total,
+		Unit:          st.Counter.Unit,
+		Started:       op.Started,
+		Columns:       pgsp.ColumnsOf(v),
+		Row:           pgsp.RowOf(v),
+	}
+	if op.HasRate {
+		rate := op.Rate
+		r.Rate = &rate
+	}
+	if op.HasPhaseETA {
+		eta := op.PhaseETA.Seconds()
+		r.PhaseETA = &eta
+	}
+	if !op.Running() {
+		finished := op.Finished
+		r.Finished = &finished
+		r.Outcome = op.Outcome.String()
+	}
+	return r
+}
@@ -0,0 +1,76 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+func TestJSON_Print(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	op := pgsp.Operation{
+		ID:          1,
+		Server:      "primary",
+		Target:      pgsp.SPVacuum,
+		Progress:    pgsp.Vacuum{PID: 10, RELID: 1, RelName: "public.t", PHASE: "scanning heap", HeapBLKSTotal: 100, HeapBLKSScanned: 10},
+		Started:     start,
+		Rate:        5,
+		HasRate:     true,
+		PhaseETA:    18 * time.Second,
+		HasPhaseETA: true,
+	}
+	s := pgsp.Snapshot{
+		Time:       start.Add(2 * time.Second),
+		Operations: []pgsp.Operation{op},
+		Servers:    []pgsp.ServerState{{Name: "primary", Targets: "Vacuum"}},
+		Events: []pgsp.Event{&pgsp.PhaseChanged{
+			EventInfo: pgsp.EventInfo{Operation: op, Time: start.Add(2 * time.Second)},
+			From:      "initializing",
+			To:        "scanning heap",
+		}},
+	}
+	buf := new(bytes.Buffer)
+	if err := NewJSON(buf).Print(s); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("JSON.Print() = %d lines, want 2", len(lines))
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	got := snapshot["operations"].([]interface{})[0].(map[string]interface{})
+	want := map[string]interface{}{
+		"server":            "primary",
+		"view":              "pg_stat_progress_vacuum",
+		"relation":          "public.t",
+		"phase":             "scanning heap",
+		"percent":           float64(5),
+		"rate":              float64(5),
+		"phase_eta_seconds": float64(18),
+	}
+	for k, w := range want {
+		if got[k] != w {
+			t.Errorf("operation[%s] = %v, want %v", k, got[k], w)
+		}
+	}
+	row := got["row"].(map[string]interface{})
+	if row["heap_blks_scanned"] != float64(10) || row["phase"] != "scanning heap" {
+		t.Errorf("row = %v", row)
+	}
+
+	var event Record
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "event" || event.Event != "phase_changed" || event.From != "initializing" || event.Operation.Pid != 10 {
+		t.Errorf("event = %+v", event)
+	}
+}
@@ -0,0 +1,155 @@
+// Package output writes the snapshots of pgsp.Watcher without the terminal UI.
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+// Printer prints the snapshots.
+type Printer interface {
+	Print(s pgsp.Snapshot) error
+}
+
+// Run prints the snapshots until the channel is closed.
+func Run(snapshots <-chan pgsp.Snapshot, p Printer) error {
+	for s := range snapshots {
+		if err := p.Print(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Multi prints the snapshots with all the printers.
+type Multi []Printer
+
+func (m Multi) Print(s pgsp.Snapshot) error {
+	for _, p := range m {
+		if err := p.Print(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TimeFormat is the format of the time of the lines.
+var TimeFormat = time.RFC3339
+
+// Plain prints a timestamped line for each change of an operation
+// and a summary line when an operation finishes.
+type Plain struct {
+	w      io.Writer
+	last   map[uint64]change
+	status string
+}
+
+// change is the state of an operation printed last.
+type change struct {
+	phase   string
+	counter pgsp.Counter
+}
+
+func NewPlain(w io.Writer) *Plain {
+	return &Plain{
+		w:    w,
+		last: make(map[uint64]change),
+	}
+}
+
+func (p *Plain) Print(s pgsp.Snapshot) error {
+	if status := status(s); status != p.status {
+		p.status = status
+		if status != "" {
+			if _, err := fmt.Fprintln(p.w, s.Time.Format(TimeFormat), status); err != nil {
+				return err
+			}
+		}
+	}
+	for _, op := range s.Operations {
+		if !op.Running() {
+			continue
+		}
+		c := change{phase: op.Progress.Phase(), counter: op.Progress.Counter()}
+		if last, ok := p.last[op.ID]; ok && last == c {
+			continue
+		}
+		p.last[op.ID] = c
+		if _, err := fmt.Fprintln(p.w, s.Time.Format(TimeFormat), Line(op)); err != nil {
+			return err
+		}
+	}
+	for _, e := range s.Events {
+		f, ok := e.(*pgsp.OperationFinished)
+		if !ok {
+			continue
+		}
+		delete(p.last, f.Operation.ID)
+		if _, err := fmt.Fprintln(p.w, f.Time.Format(TimeFormat), Summary(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Line returns a line of the running operation:
+// view, pid, relation, phase, percent (or counters if the total is unknown), rate and phase ETA.
+func Line(op pgsp.Operation) string {
+	v := op.Progress
+	st := v.Status()
+	ss := []string{describe(op)}
+	if st.Phase != "" {
+		ss = append(ss, fmt.Sprintf("phase=%q", st.Phase))
+	}
+	if st.Indeterminate {
+		if st.Counter.Unit != "" {
+			ss = append(ss, st.Counter.String())
+		}
+	} else {
+		ss = append(ss, fmt.Sprintf("%.1f%%", st.Overall*100))
+	}
+	if e := op.Estimate(); e != "" {
+		ss = append(ss, e)
+	}
+	return strings.Join(ss, " ")
+}
+
+// Summary returns a line of the finished operation.
+func Summary(f *pgsp.OperationFinished) string {
+	return fmt.Sprintf("%s finished outcome=%s duration=%s", describe(f.Operation), f.Outcome, f.Duration.Round(time.Second))
+}
+
+func describe(op pgsp.Operation) string {
+	v := op.Progress
+	s := fmt.Sprintf("%s pid=%d", v.Name(), v.Pid())
+	if op.Server != "" {
+		s = op.Server + " " + s
+	}
+	if rel := v.Relation(); rel != "" {
+		s += " relation=" + rel
+	}
+	return s
+}
+
+// status returns the status of the servers and the targets that are not polled normally.
+func status(s pgsp.Snapshot) string {
+	var ss []string
+	for _, srv := range s.Servers {
+		if srv.Err == nil {
+			continue
+		}
+		st := fmt.Sprintf("reconnecting (attempt %d): %s", srv.Attempt, srv.Err)
+		if srv.Name != "" {
+			st = srv.Name + " " + st
+		}
+		ss = append(ss, st)
+	}
+	if s.Status != "" {
+		ss = append(ss, strings.ReplaceAll(s.Status, "\n", "; "))
+	}
+	return strings.Join(ss, "; ")
+}
@@ -0,0 +1,50 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+func TestPlain_Print(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	vacuum := func(phase string, scanned int64) pgsp.Vacuum {
+		return pgsp.Vacuum{PID: 10, RELID: 1, RelName: "public.t", PHASE: phase, HeapBLKSTotal: 100, HeapBLKSScanned: scanned}
+	}
+	op := func(v pgsp.Vacuum) pgsp.Operation {
+		return pgsp.Operation{ID: 1, Target: pgsp.SPVacuum, Progress: v, Started: start}
+	}
+	finished := op(vacuum("scanning heap", 50))
+	finished.Finished = start.Add(3 * time.Second)
+	snapshots := []pgsp.Snapshot{
+		{Time: start, Operations: []pgsp.Operation{op(vacuum("scanning heap", 10))}},
+		// No change.
+		{Time: start.Add(time.Second), Operations: []pgsp.Operation{op(vacuum("scanning heap", 10))}},
+		{Time: start.Add(2 * time.Second), Operations: []pgsp.Operation{op(vacuum("scanning heap", 50))}},
+		{
+			Time:       start.Add(3 * time.Second),
+			Operations: []pgsp.Operation{finished},
+			Events: []pgsp.Event{&pgsp.OperationFinished{
+				EventInfo: pgsp.EventInfo{Operation: finished, Time: start.Add(3 * time.Second)},
+				Duration:  3 * time.Second,
+				Outcome:   pgsp.OutcomeCompleted,
+			}},
+		},
+	}
+	buf := new(bytes.Buffer)
+	p := NewPlain(buf)
+	for _, s := range snapshots {
+		if err := p.Print(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := `2024-01-01T00:00:00Z pg_stat_progress_vacuum pid=10 relation=public.t phase="scanning heap" 5.0%
+2024-01-01T00:00:02Z pg_stat_progress_vacuum pid=10 relation=public.t phase="scanning heap" 25.0%
+2024-01-01T00:00:03Z pg_stat_progress_vacuum pid=10 relation=public.t finished outcome=completed duration=3s
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Plain.Print() = \n%s, want \n%s", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/noborus/pgsp"
+)
+
+// Recorder writes a snapshot record for each polled snapshot (JSON Lines)
+// to keep the session.
+// Unlike JSON, the events are not written because they can be derived from the snapshots.
+type Recorder struct {
+	enc *json.Encoder
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+func (r *Recorder) Print(s pgsp.Snapshot) error {
+	return r.enc.Encode(SnapshotRecord(s))
+}
+
+// Tee prints the snapshots with p and passes them to the returned channel.
+// Printing stops at the first error, which is passed to errf.
+func Tee(snapshots <-chan pgsp.Snapshot, p Printer, errf func(error)) <-chan pgsp.Snapshot {
+	ch := make(chan pgsp.Snapshot, 1)
+	go func() {
+		defer close(ch)
+		for s := range snapshots {
+			if p != nil {
+				if err := p.Print(s); err != nil {
+					errf(err)
+					p = nil
+				}
+			}
+			ch <- s
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,176 @@
+package output
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+func TestRecorder_Print(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	op := pgsp.Operation{
+		ID:       1,
+		Server:   "primary",
+		Target:   pgsp.SPBaseBackup,
+		Progress: pgsp.BaseBackup{PID: 10, PHASE: "streaming database files", BackupTotal: sql.NullInt64{Int64: 100, Valid: true}, BackupStreamed: 10},
+		Started:  start,
+	}
+	buf := new(bytes.Buffer)
+	r := NewRecorder(buf)
+	for i := 0; i < 2; i++ {
+		s := pgsp.Snapshot{
+			Time:       start.Add(time.Duration(i) * time.Second),
+			Operations: []pgsp.Operation{op},
+			Servers:    []pgsp.ServerState{{Name: "primary", Targets: "BaseBackup"}},
+			Events:     []pgsp.Event{&pgsp.OperationStarted{EventInfo: pgsp.EventInfo{Operation: op, Time: start}}},
+		}
+		if err := r.Print(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Recorder.Print() = %d lines, want 2", len(lines))
+	}
+	var got Record
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "snapshot" || !got.Time.Equal(start.Add(time.Second)) {
+		t.Errorf("Recorder.Print() = %s %s", got.Type, got.Time)
+	}
+	if len(got.Operations) != 1 || got.Operations[0].Server != "primary" || got.Operations[0].View != "pg_stat_progress_basebackup" {
+		t.Fatalf("Recorder.Print() operations = %+v", got.Operations)
+	}
+	if got.Operations[0].Row["backup_streamed"] != float64(10) {
+		t.Errorf("Recorder.Print() row = %v", got.Operations[0].Row)
+	}
+}
+
+type failPrinter struct{ n int }
+
+func (p *failPrinter) Print(pgsp.Snapshot) error {
+	p.n++
+	return errors.New("fail")
+}
+
+func TestTee(t *testing.T) {
+	in := make(chan pgsp.Snapshot, 3)
+	for i := 0; i < 3; i++ {
+		in <- pgsp.Snapshot{}
+	}
+	close(in)
+	p := &failPrinter{}
+	var errs int
+	n := 0
+	for range Tee(in, p, func(error) { errs++ }) {
+		n++
+	}
+	if n != 3 || p.n != 1 || errs != 1 {
+		t.Errorf("Tee() passed %d, printed %d, errors %d", n, p.n, errs)
+	}
+}
+
+func TestReadSnapshots(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	vacuum := pgsp.Operation{
+		ID:          1,
+		Server:      "primary",
+		Target:      pgsp.SPVacuum,
+		Progress:    pgsp.Vacuum{PID: 10, DATID: 5, RELID: 16384, RelName: "public.t", PHASE: "scanning heap", HeapBLKSTotal: 1 << 40, HeapBLKSScanned: 1<<40 - 1},
+		Started:     start,
+		Rate:        5,
+		HasRate:     true,
+		PhaseETA:    1500 * time.Millisecond,
+		HasPhaseETA: true,
+	}
+	copying := pgsp.Operation{
+		ID:       2,
+		Server:   "primary",
+		Target:   pgsp.SPCopy,
+		Progress: pgsp.Copy{PID: 11, COMMAND: "COPY FROM", BYTESProcessed: 10},
+		Started:  start,
+		Finished: start.Add(time.Second),
+		Outcome:  pgsp.OutcomeFailed,
+	}
+	want := pgsp.Snapshot{
+		Time:       start.Add(2 * time.Second),
+		Operations: []pgsp.Operation{vacuum, copying},
+		Servers:    []pgsp.ServerState{{Name: "primary", Targets: "Vacuum Copy", Err: errors.New("lost"), Attempt: 2}},
+		Events:     []pgsp.Event{&pgsp.OperationStarted{EventInfo: pgsp.EventInfo{Operation: vacuum, Time: start}}},
+	}
+	buf := new(bytes.Buffer)
+	// The events of --output json are skipped.
+	if err := NewJSON(buf).Print(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSnapshots(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("ReadSnapshots() = %d snapshots, want 1", len(got))
+	}
+	s := got[0]
+	if !s.Time.Equal(want.Time) || s.Targets != "primary: Vacuum Copy" {
+		t.Errorf("ReadSnapshots() = %s %q", s.Time, s.Targets)
+	}
+	if len(s.Servers) != 1 || s.Servers[0].Err == nil || s.Servers[0].Err.Error() != "lost" || s.Servers[0].Attempt != 2 {
+		t.Errorf("ReadSnapshots() servers = %+v", s.Servers)
+	}
+	if len(s.Operations) != 2 {
+		t.Fatalf("ReadSnapshots() = %d operations, want 2", len(s.Operations))
+	}
+	op := s.Operations[0]
+	v, ok := op.Progress.(pgsp.Vacuum)
+	if !ok {
+		t.Fatalf("ReadSnapshots() progress = %T", op.Progress)
+	}
+	if v.HeapBLKSScanned != 1<<40-1 || v.Relation() != "public.t" || v.PHASE != "scanning heap" {
+		t.Errorf("ReadSnapshots() vacuum = %+v", v)
+	}
+	if op.ID != 1 || op.Server != "primary" || !op.Running() || op.Rate != 5 || op.PhaseETA != 1500*time.Millisecond {
+		t.Errorf("ReadSnapshots() operation = %+v", op)
+	}
+	op = s.Operations[1]
+	if op.Running() || op.Outcome != pgsp.OutcomeFailed || op.Progress.Counter().Done != 10 {
+		t.Errorf("ReadSnapshots() copy = %+v", op)
+	}
+}
+
+func TestReadSnapshots_version(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pg16 := pgsp.WithColumns(pgsp.Vacuum{PID: 10, PHASE: "vacuuming indexes", MaxDeadTuples: 1000, NumDeadTuples: 10}, pgsp.VacuumVersionColumns.Columns(160000))
+	s := pgsp.Snapshot{
+		Time:       start,
+		Operations: []pgsp.Operation{{ID: 1, Target: pgsp.SPVacuum, Progress: pg16, Started: start}},
+	}
+	buf := new(bytes.Buffer)
+	if err := NewRecorder(buf).Print(s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSnapshots(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0].Operations) != 1 {
+		t.Fatalf("ReadSnapshots() = %+v", got)
+	}
+	v := got[0].Operations[0].Progress
+	if !reflect.DeepEqual(pgsp.ColumnsOf(v), pgsp.VacuumVersionColumns.Columns(160000)) {
+		t.Errorf("ReadSnapshots() columns = %v, want the columns of 16", pgsp.ColumnsOf(v))
+	}
+	if v.Vertical() != pg16.Vertical() {
+		t.Errorf("ReadSnapshots() = \n%s\nwant\n%s", v.Vertical(), pg16.Vertical())
+	}
+	if !reflect.DeepEqual(pgsp.RowOf(v), pgsp.RowOf(pg16)) {
+		t.Errorf("ReadSnapshots() row = %v, want %v", pgsp.RowOf(v), pgsp.RowOf(pg16))
+	}
+}
@@ -0,0 +1,158 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+// ReadSnapshots reads the snapshot records of a recorded session
+// (--record or --output json) and returns the snapshots.
+// The events and the operations of the targets that are not registered are skipped.
+func ReadSnapshots(r io.Reader) ([]pgsp.Snapshot, error) {
+	dec := json.NewDecoder(r)
+	// Keep the counters of int64 exactly.
+	dec.UseNumber()
+	p := newPlayer()
+	var snapshots []pgsp.Snapshot
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return snapshots, nil
+			}
+			return snapshots, err
+		}
+		if rec.Type != "snapshot" {
+			continue
+		}
+		s, err := p.snapshot(rec)
+		if err != nil {
+			return snapshots, err
+		}
+		snapshots = append(snapshots, s)
+	}
+}
+
+// player decodes the records into snapshots.
+type player struct {
+	tables pgsp.StatProgress
+	// resolver restores the recorded names of the relations.
+	resolver *pgsp.Resolver
+}
+
+func newPlayer() *player {
+	return &player{
+		tables:   pgsp.NewMonitor(),
+		resolver: pgsp.NewResolver("", nil),
+	}
+}
+
+func (p *player) snapshot(rec Record) (pgsp.Snapshot, error) {
+	s := pgsp.Snapshot{
+		Time:       rec.Time,
+		Operations: make([]pgsp.Operation, 0, len(rec.Operations)),
+		Servers:    make([]pgsp.ServerState, 0, len(rec.Servers)),
+	}
+	var targets, status []string
+	for _, sr := range rec.Servers {
+		st := pgsp.ServerState{
+			Name:    sr.Name,
+			Targets: sr.Targets,
+			Status:  sr.Status,
+			Attempt: sr.Attempt,
+		}
+		if sr.Error != "" {
+			st.Err = errors.New(sr.Error)
+		}
+		s.Servers = append(s.Servers, st)
+		targets = append(targets, pgsp.Prefixed(sr.Name, sr.Targets))
+		if sr.Status != "" {
+			status = append(status, pgsp.Prefixed(sr.Name, sr.Status))
+		}
+	}
+	s.Targets = strings.Join(targets, " ")
+	s.Status = strings.Join(status, "\n")
+
+	for _, or := range rec.Operations {
+		op, ok, err := p.operation(rec.Time, or)
+		if err != nil {
+			return s, err
+		}
+		if ok {
+			s.Operations = append(s.Operations, op)
+		}
+	}
+	return s, nil
+}
+
+// operation returns the operation of the record.
+// Returns false if the target is not registered.
+func (p *player) operation(now time.Time, or OperationRecord) (pgsp.Operation, bool, error) {
+	table, ok := p.tables[or.Target]
+	if !ok || table.Decode == nil {
+		return pgsp.Operation{}, false, nil
+	}
+	data, err := json.Marshal(or.Row)
+	if err != nil {
+		return pgsp.Operation{}, false, err
+	}
+	v, err := table.Decode(data)
+	if err != nil {
+		return pgsp.Operation{}, false, err
+	}
+	// The row has the columns of the server version it was recorded from.
+	v = pgsp.WithColumns(v, or.Columns)
+	v = p.resolve(v, or)
+
+	op := pgsp.Operation{
+		ID:       or.ID,
+		Server:   or.Server,
+		Target:   or.Target,
+		Progress: v,
+		Started:  or.Started,
+		LastSeen: now,
+	}
+	if or.Finished != nil {
+		op.Finished = *or.Finished
+		op.LastSeen = op.Finished
+		op.Outcome = pgsp.ParseOutcome(or.Outcome)
+	}
+	if or.Rate != nil {
+		op.Rate, op.HasRate = *or.Rate, true
+	}
+	if or.PhaseETA != nil {
+		op.PhaseETA, op.HasPhaseETA = time.Duration(*or.PhaseETA*float64(time.Second)), true
+	}
+	return op, true, nil
+}
+
+// resolve restores the recorded name of the relation
+// because the row has only the OID.
+func (p *player) resolve(v pgsp.Progress, or OperationRecord) pgsp.Progress {
+	r, ok := v.(pgsp.Resolvable)
+	if !ok || or.Relation == "" || or.Relation == v.Relation() {
+		return v
+	}
+	datid, _ := rowInt(or.Row, "datid")
+	relid, ok := rowInt(or.Row, "relid")
+	if !ok {
+		return v
+	}
+	p.resolver.SetName(datid, relid, or.Relation)
+	return r.Resolve(context.Background(), p.resolver)
+}
+
+func rowInt(row map[string]interface{}, key string) (int, bool) {
+	n, ok := row[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
@@ -3,12 +3,17 @@ package pgsp
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"math"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"github.com/noborus/pgsp/str"
 )
 
 type SPTaget string
@@ -24,22 +29,54 @@ const (
 
 type SPTable struct {
 	Enable bool
-	Get    func(ctx context.Context, db *sqlx.DB) ([]Progress, error)
+	// Supported is false if the view does not exist in the server version.
+	Supported  bool
+	Get        func(ctx context.Context, db *sqlx.DB) ([]Progress, error)
+	SetVersion func(version int) bool
+	// Query returns the query of Get to be combined into one round trip.
+	Query func() string
+	// Decode decodes a row of Query returned as JSON.
+	Decode func(data []byte) (Progress, error)
+
+	// Err is the last error of Get.
+	Err error
+	// Failures is the number of consecutive transient failures.
+	Failures int
+	// RetryAt is the time to retry after a transient failure.
+	RetryAt time.Time
 }
 
 type StatProgress map[SPTaget]*SPTable
 
 type Pgsp struct {
 	DB           *sqlx.DB
+	Version      int
 	StatProgress StatProgress
+	Resolver     *Resolver
+
+	// mu protects stmts from DisConnect while polling.
+	mu    sync.Mutex
+	stmts map[string]*sqlx.Stmt
+
+	// lost is the error while the connection is lost.
+	lost        error
+	attempts    int
+	reconnectAt time.Time
 }
 
 type Progress interface {
 	Name() string
 	Pid() int
+	Relation() string
+	Session() Activity
 	Color() (string, string)
 	Table() string
 	Vertical() string
+	Phase() string
+	Counter() Counter
+	Status() Status
+	// Progress returns the overall completion (0 to 1).
+	// Status().Indeterminate reports whether the total of the current phase is unknown.
 	Progress() float64
 }
 
@@ -48,34 +85,72 @@ func New(dsn string) (*Pgsp, error) {
 	if err != nil {
 		return nil, err
 	}
+	version, err := ServerVersion(context.Background(), db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 	monitor := NewMonitor()
+	monitor.SetVersion(version)
 	return &Pgsp{
 		DB:           db,
+		Version:      version,
 		StatProgress: monitor,
+		Resolver:     NewResolver(dsn, db),
 	}, nil
 }
 
-func NewMonitor() StatProgress {
-	return StatProgress{
-		SPAnalyze: {
-			Get: GetAnalyze,
-		},
-		SPCreateIndex: {
-			Get: GetCreateIndex,
-		},
-		SPVacuum: {
-			Get: GetVacuum,
-		},
-		SPCluster: {
-			Get: GetCluster,
-		},
-		SPBaseBackup: {
-			Get: GetBaseBackup,
-		},
-		SPCopy: {
-			Get: GetCopy,
-		},
+// Open returns the monitor of the server of dsn like New.
+// If the server is unreachable, the monitor starts with the connection lost
+// and Poll connects with backoff. Other errors (e.g. authentication) are returned.
+func Open(dsn string) (*Pgsp, error) {
+	p, err := New(dsn)
+	if err == nil || !unreachable(err) {
+		return p, err
 	}
+	db, openErr := sqlx.Open("postgres", dsn)
+	if openErr != nil {
+		return nil, openErr
+	}
+	p = &Pgsp{
+		DB:           db,
+		StatProgress: NewMonitor(),
+		Resolver:     NewResolver(dsn, db),
+	}
+	p.connectionLost(time.Now(), err)
+	return p, nil
+}
+
+func init() {
+	Register(SPAnalyze, newView[Analyze](AnalyzeTableName, AnalyzeVersionColumns))
+	Register(SPCreateIndex, newView[CreateIndex](CreateIndexTableName, CreateIndexVersionColumns))
+	Register(SPVacuum, newView[Vacuum](VacuumTableName, VacuumVersionColumns))
+	Register(SPCluster, newView[Cluster](ClusterTableName, ClusterVersionColumns))
+	Register(SPBaseBackup, newView[BaseBackup](BaseBackupTableName, BaseBackupVersionColumns))
+	Register(SPCopy, newView[Copy](CopyTableName, CopyVersionColumns))
+}
+
+// SetVersion selects the columns of each view for the server version.
+// Views that do not exist in the server version are marked as unsupported.
+func (sp StatProgress) SetVersion(version int) {
+	for _, t := range sp {
+		if t.SetVersion == nil {
+			continue
+		}
+		t.Supported = t.SetVersion(version)
+		if !t.Supported {
+			t.Enable = false
+		}
+	}
+}
+
+// ServerVersion returns the server_version_num of the connected server.
+func ServerVersion(ctx context.Context, db *sqlx.DB) (int, error) {
+	var version int
+	if err := db.GetContext(ctx, &version, "SELECT current_setting('server_version_num')::int"); err != nil {
+		return 0, err
+	}
+	return version, nil
 }
 
 func Connect(dsn string) (*sqlx.DB, error) {
@@ -90,14 +165,37 @@ func Connect(dsn string) (*sqlx.DB, error) {
 }
 
 func (p *Pgsp) DisConnect() error {
+	p.closeStmts()
+	if err := p.Resolver.Close(); err != nil {
+		return err
+	}
 	return p.DB.Close()
 }
 
+// Get returns the progress of the target with the names of the relations resolved.
+func (p *Pgsp) Get(ctx context.Context, target SPTaget) ([]Progress, error) {
+	table, ok := p.StatProgress[target]
+	if !ok {
+		return nil, fmt.Errorf("unknown target: %s", target)
+	}
+	result, err := table.Get(ctx, p.DB)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range result {
+		if r, ok := v.(Resolvable); ok {
+			result[i] = r.Resolve(ctx, p.Resolver)
+		}
+	}
+	return result, nil
+}
+
 func (p *Pgsp) Targets(target []string) {
 	if len(target) != 0 {
 		enableF := false
 		for _, t := range target {
-			if v, ok := p.StatProgress[SPTaget(t)]; ok {
+			name, _ := LookupTarget(t)
+			if v, ok := p.StatProgress[name]; ok && v.Supported {
 				enableF = true
 				v.Enable = true
 			}
@@ -108,9 +206,9 @@ func (p *Pgsp) Targets(target []string) {
 		}
 	}
 
-	// All targets.
+	// All supported targets.
 	for _, v := range p.StatProgress {
-		v.Enable = true
+		v.Enable = v.Supported
 	}
 }
 
@@ -125,22 +223,61 @@ func (p *Pgsp) TargetString() string {
 	return strings.Join(ms, " ")
 }
 
+// buildQuery builds a query that selects the columns of the view
+// joined with the session in pg_stat_activity.
 func buildQuery(tableName string, columns []string) string {
 	buff := new(bytes.Buffer)
 	buff.WriteString("SELECT ")
-	buff.WriteString(strings.Join(columns, ", "))
+	for i, c := range columns {
+		if i > 0 {
+			buff.WriteString(", ")
+		}
+		buff.WriteString("p.")
+		buff.WriteString(c)
+	}
+	for _, c := range activityColumns {
+		buff.WriteString(", ")
+		buff.WriteString(c)
+	}
 	buff.WriteString(" FROM ")
 	buff.WriteString(tableName)
+	buff.WriteString(" p LEFT JOIN pg_stat_activity a ON a.pid = p.pid")
 	return buff.String()
 }
 
 func getColumns(s interface{}) []string {
-	t := reflect.TypeOf(s)
-	var columns []string
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		j := field.Tag.Get("db")
-		columns = append(columns, j)
-	}
-	return columns
+	columns := rowTypeOf(reflect.TypeOf(s)).columns
+	return append([]string(nil), columns...)
+}
+
+// VersionColumns is the columns of the view for each server version(server_version_num).
+// The key is the first version that has the columns.
+type VersionColumns map[int][]string
+
+// Columns returns the columns for the server version.
+// Returns nil if the view does not exist in the version.
+func (vc VersionColumns) Columns(version int) []string {
+	found := -1
+	for v := range vc {
+		if v <= version && v > found {
+			found = v
+		}
+	}
+	if found < 0 {
+		return nil
+	}
+	return vc[found]
+}
+
+// Latest returns the columns of the latest version.
+func (vc VersionColumns) Latest() []string {
+	return vc.Columns(math.MaxInt32)
+}
+
+func toStrings(values []interface{}) []string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = str.ToStr(v)
+	}
+	return strs
 }
@@ -0,0 +1,89 @@
+package pgsp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestVersionColumns_Columns(t *testing.T) {
+	vc := VersionColumns{
+		130000: {"a", "b"},
+		170000: {"a", "c"},
+	}
+	tests := []struct {
+		name    string
+		version int
+		want    []string
+	}{
+		{
+			name:    "unsupported",
+			version: 120016,
+			want:    nil,
+		},
+		{
+			name:    "first",
+			version: 130000,
+			want:    []string{"a", "b"},
+		},
+		{
+			name:    "between",
+			version: 160004,
+			want:    []string{"a", "b"},
+		},
+		{
+			name:    "latest",
+			version: 170002,
+			want:    []string{"a", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vc.Columns(tt.version); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VersionColumns.Columns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVacuum_Vertical17(t *testing.T) {
+	v := Vacuum{
+		PID:              1,
+		PHASE:            "vacuuming indexes",
+		NumDeadTuples:    9,
+		NumDeadItemIDs:   2,
+		IndexesTotal:     3,
+		IndexesProcessed: 1,
+	}
+	v.columns = VacuumVersionColumns.Columns(170000)
+	want := ` pid                  | 1
+ datid                | 0
+ datname              | 
+ relid                | 0
+ phase                | vacuuming indexes
+ heap_blks_total      | 0
+ heap_blks_scanned    | 0
+ heap_blks_vacuumed   | 0
+ index_vacuum_count   | 0
+ max_dead_tuple_bytes | 0
+ dead_tuple_bytes     | 0
+ num_dead_item_ids    | 2
+ indexes_total        | 3
+ indexes_processed    | 1
+`
+	if got := v.Vertical(); got != want {
+		t.Errorf("Vacuum.Vertical() = %v, want %v", got, want)
+	}
+}
+
+// The Get functions of the views can be the Get of SPTable.
+var (
+	_ func(context.Context, *sqlx.DB) ([]Progress, error) = GetVacuum
+	_ func(context.Context, *sqlx.DB) ([]Progress, error) = GetAnalyze
+	_ func(context.Context, *sqlx.DB) ([]Progress, error) = GetCreateIndex
+	_ func(context.Context, *sqlx.DB) ([]Progress, error) = GetCluster
+	_ func(context.Context, *sqlx.DB) ([]Progress, error) = GetBaseBackup
+	_ func(context.Context, *sqlx.DB) ([]Progress, error) = GetCopy
+)
@@ -0,0 +1,209 @@
+package pgsp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Status is the phase-aware progress of an operation.
+type Status struct {
+	// Phase is the current phase.
+	Phase string
+	// Index is the index of Phase in Phases, or -1 if the phase is unknown.
+	Index int
+	// Phases is the known phases of the command.
+	Phases []string
+	// Counter is the amount of work of the current phase.
+	Counter Counter
+	// Extra is the other absolute counters of the current phase.
+	Extra []Counter
+	// Indeterminate is true if the total of the current phase is unknown.
+	// PhaseProgress is 0 and Overall does not include the current phase.
+	Indeterminate bool
+	// PhaseProgress is the completion of the current phase (0 to 1).
+	PhaseProgress float64
+	// Overall is the weighted completion of the whole operation (0 to 1).
+	Overall float64
+}
+
+// Counters returns the absolute counters of the current phase separated by commas.
+func (s Status) Counters() string {
+	var cs []string
+	if s.Counter.Unit != "" {
+		cs = append(cs, s.Counter.String())
+	}
+	for _, c := range s.Extra {
+		cs = append(cs, c.String())
+	}
+	return strings.Join(cs, ", ")
+}
+
+// PhaseWeight is a phase of a command and its weight in the overall completion.
+type PhaseWeight struct {
+	Name   string
+	Weight float64
+}
+
+// Phases is the known phases of a command in the order of execution.
+// Phases that have been skipped are considered complete when a later phase is reached.
+type Phases []PhaseWeight
+
+// Index returns the index of the phase.
+// A phase whose sub phase is not known ("building index: unknown"),
+// or that has no sub phase ("building index"), matches the first phase of its main phase.
+func (ps Phases) Index(phase string) int {
+	for i, p := range ps {
+		if phase == p.Name {
+			return i
+		}
+	}
+	main, _, _ := strings.Cut(phase, ":")
+	for i, p := range ps {
+		if p.Name == main || strings.HasPrefix(p.Name, main+":") {
+			return i
+		}
+	}
+	return -1
+}
+
+// Names returns the names of the phases.
+func (ps Phases) Names() []string {
+	names := make([]string, len(ps))
+	for i, p := range ps {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// Status returns the status of the phase with the counter of the phase.
+func (ps Phases) Status(phase string, c Counter) Status {
+	s := Status{
+		Phase:         phase,
+		Index:         ps.Index(phase),
+		Phases:        ps.Names(),
+		Counter:       c,
+		PhaseProgress: c.Progress(),
+		Indeterminate: c.Total <= 0,
+	}
+	s.Overall = ps.overall(s.Index, s.PhaseProgress)
+	return s
+}
+
+// overall returns the weighted completion of the whole operation
+// when the phase of index has completed phaseProgress.
+func (ps Phases) overall(index int, phaseProgress float64) float64 {
+	if index < 0 {
+		return phaseProgress
+	}
+	var total, done float64
+	for i, p := range ps {
+		total += p.Weight
+		if i < index {
+			done += p.Weight
+		}
+	}
+	if total == 0 {
+		return phaseProgress
+	}
+	done += ps[index].Weight * phaseProgress
+	return done / total
+}
+
+// String returns the counter as "done / total unit", or "done unit" if the total is unknown.
+func (c Counter) String() string {
+	if c.Unit == UnitBytes {
+		if c.Total <= 0 {
+			return FormatBytes(float64(c.Done))
+		}
+		return FormatBytes(float64(c.Done)) + " / " + FormatBytes(float64(c.Total))
+	}
+	if c.Total <= 0 {
+		return fmt.Sprintf("%d %s", c.Done, c.Unit)
+	}
+	return fmt.Sprintf("%d / %d %s", c.Done, c.Total, c.Unit)
+}
+
+// Progress returns Done/Total, or 0 if Total is unknown.
+func (c Counter) Progress() float64 {
+	if c.Total <= 0 {
+		return 0
+	}
+	p := float64(c.Done) / float64(c.Total)
+	if p > 1 {
+		return 1
+	}
+	return p
+}
+
+// buildIndexPhases is the sub phases of "building index" reported by btree.
+// Other access methods report "building index" without a sub phase.
+var buildIndexPhases = Phases{
+	{"building index: scanning table", 3},
+	{"building index: sorting live tuples", 1},
+	{"building index: sorting dead tuples", 0.2},
+	{"building index: loading tuples in tree", 2},
+}
+
+// Phases of each command.
+// See https://www.postgresql.org/docs/current/progress-reporting.html.
+var (
+	VacuumPhases = Phases{
+		{"initializing", 0},
+		{"scanning heap", 6},
+		{"vacuuming indexes", 2},
+		{"vacuuming heap", 2},
+		{"cleaning up indexes", 1},
+		{"truncating heap", 0.5},
+		{"performing final cleanup", 0.5},
+	}
+	AnalyzePhases = Phases{
+		{"initializing", 0},
+		{"acquiring sample rows", 6},
+		{"acquiring inherited sample rows", 6},
+		{"computing statistics", 1},
+		{"computing extended statistics", 1},
+		{"finalizing analyze", 0.5},
+	}
+	CreateIndexPhases = append(Phases{
+		{"initializing", 0},
+	}, buildIndexPhases...)
+	CreateIndexConcurrentlyPhases = append(append(Phases{
+		{"initializing", 0},
+		{"waiting for writers before build", 0.5},
+	}, buildIndexPhases...), Phases{
+		{"waiting for writers before validation", 0.5},
+		{"index validation: scanning index", 1},
+		{"index validation: sorting tuples", 0.5},
+		{"index validation: scanning table", 2},
+		{"waiting for old snapshots", 0.5},
+		{"waiting for readers before marking dead", 0.2},
+		{"waiting for readers before dropping", 0.2},
+	}...)
+	// ClusterPhases is the phases of CLUSTER with a sequential scan and sort, and of VACUUM FULL.
+	ClusterPhases = Phases{
+		{"initializing", 0},
+		{"seq scanning heap", 4},
+		{"sorting tuples", 1},
+		{"writing new heap", 3},
+		{"swapping relation files", 0.5},
+		{"rebuilding index", 2},
+		{"performing final cleanup", 0.5},
+	}
+	// ClusterIndexScanPhases is the phases of CLUSTER with an index scan,
+	// which writes the new heap while scanning instead of sorting.
+	ClusterIndexScanPhases = Phases{
+		{"initializing", 0},
+		{"index scanning heap", 7},
+		{"swapping relation files", 0.5},
+		{"rebuilding index", 2},
+		{"performing final cleanup", 0.5},
+	}
+	BaseBackupPhases = Phases{
+		{"initializing", 0},
+		{"waiting for checkpoint to finish", 0.5},
+		{"estimating backup size", 0.5},
+		{"streaming database files", 8},
+		{"waiting for wal archiving to finish", 0.5},
+		{"transferring wal files", 0.5},
+	}
+)
@@ -0,0 +1,111 @@
+package pgsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPhases_Status(t *testing.T) {
+	tests := []struct {
+		name        string
+		ps          Phases
+		phase       string
+		c           Counter
+		wantIndex   int
+		wantPhase   float64
+		wantOverall float64
+	}{
+		{
+			name:        "first",
+			ps:          VacuumPhases,
+			phase:       "initializing",
+			wantIndex:   0,
+			wantOverall: 0,
+		},
+		{
+			name:        "scanning",
+			ps:          VacuumPhases,
+			phase:       "scanning heap",
+			c:           Counter{Done: 50, Total: 100, Unit: UnitBlocks},
+			wantIndex:   1,
+			wantPhase:   0.5,
+			wantOverall: 3.0 / 12,
+		},
+		{
+			name:        "later",
+			ps:          VacuumPhases,
+			phase:       "vacuuming heap",
+			c:           Counter{Done: 25, Total: 100, Unit: UnitBlocks},
+			wantIndex:   3,
+			wantPhase:   0.25,
+			wantOverall: 8.5 / 12,
+		},
+		{
+			name:        "subPhase",
+			ps:          CreateIndexPhases,
+			phase:       "building index: scanning table",
+			c:           Counter{Done: 10, Total: 40, Unit: UnitBlocks},
+			wantIndex:   1,
+			wantPhase:   0.25,
+			wantOverall: 0.25 * 3 / 6.2,
+		},
+		{
+			name:        "laterSubPhase",
+			ps:          CreateIndexPhases,
+			phase:       "building index: loading tuples in tree",
+			c:           Counter{Done: 50, Total: 100, Unit: UnitTuples},
+			wantIndex:   4,
+			wantPhase:   0.5,
+			wantOverall: 5.2 / 6.2,
+		},
+		{
+			name:        "noSubPhase",
+			ps:          CreateIndexPhases,
+			phase:       "building index",
+			c:           Counter{Done: 10, Total: 40, Unit: UnitBlocks},
+			wantIndex:   1,
+			wantPhase:   0.25,
+			wantOverall: 0.25 * 3 / 6.2,
+		},
+		{
+			name:        "unknownSubPhase",
+			ps:          CreateIndexConcurrentlyPhases,
+			phase:       "building index: scanning posting tree",
+			wantIndex:   2,
+			wantOverall: 0.5 / 11.6,
+		},
+		{
+			name:        "unknown",
+			ps:          CreateIndexPhases,
+			phase:       "unknown phase",
+			c:           Counter{Done: 1, Total: 2, Unit: UnitBlocks},
+			wantIndex:   -1,
+			wantPhase:   0.5,
+			wantOverall: 0.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.ps.Status(tt.phase, tt.c)
+			if got.Index != tt.wantIndex {
+				t.Errorf("Phases.Status() Index = %v, want %v", got.Index, tt.wantIndex)
+			}
+			if math.Abs(got.PhaseProgress-tt.wantPhase) > 1e-9 {
+				t.Errorf("Phases.Status() PhaseProgress = %v, want %v", got.PhaseProgress, tt.wantPhase)
+			}
+			if math.Abs(got.Overall-tt.wantOverall) > 1e-9 {
+				t.Errorf("Phases.Status() Overall = %v, want %v", got.Overall, tt.wantOverall)
+			}
+		})
+	}
+}
+
+func TestStatus_Counters(t *testing.T) {
+	v := Copy{COMMAND: "COPY FROM", CTYPE: "PIPE", BYTESProcessed: 2048, TUPLESProcessed: 10}
+	if got, want := v.Status().Counters(), "2.0 KiB, 10 tuples"; got != want {
+		t.Errorf("Status.Counters() = %q, want %q", got, want)
+	}
+	if got := VacuumPhases.Status("initializing", Counter{}).Counters(); got != "" {
+		t.Errorf("Status.Counters() without counters = %q", got)
+	}
+}
@@ -0,0 +1,84 @@
+package pgsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[SPTaget]func() SPTable)
+)
+
+// Register makes a progress source available by the target name.
+// The source participates in targeting, polling and display like the built-in views.
+// newTable is called for each monitor, so the state of the source
+// (such as the columns for the server version) is not shared between monitors.
+// Get is required. SetVersion is optional and reports whether the source
+// is available in the server version. Query and Decode are optional and
+// combine the source into the single round trip of Poll.
+// Register panics if Get is nil or the target is registered twice,
+// or differs only in case from a registered target.
+func Register(target SPTaget, newTable func() SPTable) {
+	if err := register(target, newTable); err != nil {
+		panic(err)
+	}
+}
+
+func register(target SPTaget, newTable func() SPTable) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if newTable == nil || newTable().Get == nil {
+		return fmt.Errorf("pgsp: Register %s: Get is nil", target)
+	}
+	if _, dup := registry[target]; dup {
+		return fmt.Errorf("pgsp: Register called twice for %s", target)
+	}
+	// The targets are looked up case-insensitively.
+	for t := range registry {
+		if strings.EqualFold(string(t), string(target)) {
+			return fmt.Errorf("pgsp: Register %s: conflicts with %s", target, t)
+		}
+	}
+	registry[target] = newTable
+	return nil
+}
+
+// Registered returns the sorted names of the registered targets.
+func Registered() []SPTaget {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	targets := make([]SPTaget, 0, len(registry))
+	for target := range registry {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+	return targets
+}
+
+// LookupTarget returns the registered target of the name, which is case-insensitive.
+func LookupTarget(name string) (SPTaget, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for target := range registry {
+		if strings.EqualFold(string(target), name) {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+// NewMonitor returns a StatProgress of all registered targets.
+func NewMonitor() StatProgress {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	sp := make(StatProgress, len(registry))
+	for target, newTable := range registry {
+		t := newTable()
+		t.Supported = true
+		sp[target] = &t
+	}
+	return sp
+}
@@ -0,0 +1,78 @@
+package pgsp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// unregister removes the target registered by the tests.
+func unregister(target SPTaget) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, target)
+}
+
+func TestRegister(t *testing.T) {
+	target := SPTaget("TestRepack")
+	Register(target, func() SPTable {
+		return SPTable{
+			Get: func(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
+				return []Progress{Copy{PID: 1}}, nil
+			},
+			SetVersion: func(version int) bool { return version >= 120000 },
+		}
+	})
+	t.Cleanup(func() { unregister(target) })
+
+	p := &Pgsp{StatProgress: NewMonitor()}
+	p.StatProgress.SetVersion(110000)
+	p.Targets([]string{string(target)})
+	if p.StatProgress[target].Enable {
+		t.Errorf("Targets() enabled unsupported %s", target)
+	}
+
+	p = &Pgsp{StatProgress: NewMonitor()}
+	p.StatProgress.SetVersion(170000)
+	p.Targets([]string{"testrepack"})
+	for name, table := range p.StatProgress {
+		if table.Enable != (name == target) {
+			t.Errorf("Targets() %s Enable = %v", name, table.Enable)
+		}
+	}
+	got, err := p.Get(context.Background(), target)
+	if err != nil || len(got) != 1 || got[0].Pid() != 1 {
+		t.Errorf("Get() = %v, %v", got, err)
+	}
+
+	if err := register("testREPACK", func() SPTable { return *p.StatProgress[target] }); err == nil {
+		t.Errorf("register() of a name that differs only in case did not fail")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Register() twice did not panic")
+		}
+	}()
+	Register(target, func() SPTable { return *p.StatProgress[target] })
+}
+
+func TestLookupTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   SPTaget
+		wantOK bool
+	}{
+		{name: "Vacuum", want: SPVacuum, wantOK: true},
+		{name: "vacuum", want: SPVacuum, wantOK: true},
+		{name: "CREATEINDEX", want: SPCreateIndex, wantOK: true},
+		{name: "vacuums", want: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := LookupTarget(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("LookupTarget(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
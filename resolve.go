@@ -0,0 +1,278 @@
+package pgsp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Resolvable is implemented by Progress that has OIDs of relations.
+type Resolvable interface {
+	// Resolve returns a copy of the Progress with the names of the relations resolved.
+	Resolve(ctx context.Context, r *Resolver) Progress
+	// RelationID returns the OIDs of the database and the relation.
+	RelationID() (datid int, relid int)
+}
+
+// Resolver resolves the OIDs of relations into schema-qualified names.
+// Relations in other databases are resolved by connecting to that database,
+// and the names are cached for each database.
+// A Resolver without db resolves only the names set by SetName.
+type Resolver struct {
+	mu    sync.Mutex
+	dsn   string
+	db    *sqlx.DB
+	datid int
+	// conns is the connections to other databases, nil for a database that does not exist.
+	conns map[int]*sqlx.DB
+	// retries is the backoff of the databases that failed to be connected.
+	retries map[int]retry
+	names   map[int]map[int]string
+	// filenodes is the relfilenodes of the relations first seen by the commands.
+	filenodes map[filenodeKey]int64
+}
+
+// retry is the backoff of connecting to a database.
+type retry struct {
+	failures int
+	at       time.Time
+}
+
+// filenodeKey identifies the command that rewrites a relation.
+type filenodeKey struct {
+	datid int
+	relid int
+	pid   int
+	start time.Time
+}
+
+const relationNameQuery = `SELECT format('%I.%I', n.nspname, c.relname)
+ FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+ WHERE c.oid = $1`
+
+func NewResolver(dsn string, db *sqlx.DB) *Resolver {
+	return &Resolver{
+		dsn:       dsn,
+		db:        db,
+		conns:     make(map[int]*sqlx.DB),
+		retries:   make(map[int]retry),
+		names:     make(map[int]map[int]string),
+		filenodes: make(map[filenodeKey]int64),
+	}
+}
+
+// Name returns the schema-qualified name of the relation in the database datid.
+// Returns an empty string if it cannot be resolved.
+func (r *Resolver) Name(ctx context.Context, datid int, oid int) string {
+	if r == nil || oid == 0 {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if names, ok := r.names[datid]; ok {
+		if name, ok := names[oid]; ok {
+			return name
+		}
+	}
+
+	db := r.conn(ctx, datid)
+	if db == nil {
+		return ""
+	}
+	var name string
+	if err := db.GetContext(ctx, &name, relationNameQuery, oid); err != nil {
+		// Not cached because the relation may not be visible yet.
+		return ""
+	}
+	if r.names[datid] == nil {
+		r.names[datid] = make(map[int]string)
+	}
+	r.names[datid][oid] = name
+	return name
+}
+
+// SetName caches the name of the relation in the database datid.
+func (r *Resolver) SetName(datid int, oid int, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[datid] == nil {
+		r.names[datid] = make(map[int]string)
+	}
+	r.names[datid][oid] = name
+}
+
+// Filenode returns the relfilenode of the relation in the database datid
+// when it was first seen for the command of the backend pid started at start,
+// so that the rewrite of the relation can be detected after the command has finished.
+// If rewritten is true and it has not been seen, the relation may already be rewritten
+// and 0 is returned. Returns 0 if it cannot be fetched.
+func (r *Resolver) Filenode(ctx context.Context, datid int, relid int, pid int, start time.Time, rewritten bool) int64 {
+	if r == nil || relid == 0 {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := filenodeKey{datid: datid, relid: relid, pid: pid, start: start}
+	if filenode, ok := r.filenodes[key]; ok || rewritten {
+		return filenode
+	}
+	db := r.conn(ctx, datid)
+	if db == nil {
+		return 0
+	}
+	var filenode int64
+	if err := db.GetContext(ctx, &filenode, filenodeQuery, relid); err != nil {
+		return 0
+	}
+	r.filenodes[key] = filenode
+	return filenode
+}
+
+// ForgetFilenode deletes the relfilenode of the relation seen for the command
+// of the backend pid started at start.
+func (r *Resolver) ForgetFilenode(datid int, relid int, pid int, start time.Time) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.filenodes, filenodeKey{datid: datid, relid: relid, pid: pid, start: start})
+}
+
+// DB returns the connection to the database datid.
+// Returns nil if it cannot be connected.
+func (r *Resolver) DB(ctx context.Context, datid int) *sqlx.DB {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.conn(ctx, datid)
+}
+
+// conn returns the connection to the database datid.
+// Returns nil if it cannot be connected.
+func (r *Resolver) conn(ctx context.Context, datid int) *sqlx.DB {
+	if r.db == nil {
+		return nil
+	}
+	if r.datid == 0 {
+		err := r.db.GetContext(ctx, &r.datid, "SELECT oid FROM pg_database WHERE datname = current_database()")
+		if err != nil {
+			return nil
+		}
+	}
+	if datid == 0 || datid == r.datid {
+		return r.db
+	}
+	if db, ok := r.conns[datid]; ok {
+		return db
+	}
+	if time.Now().Before(r.retries[datid].at) {
+		return nil
+	}
+
+	var datname string
+	if err := r.db.GetContext(ctx, &datname, "SELECT datname FROM pg_database WHERE oid = $1", datid); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The database has been dropped.
+			r.conns[datid] = nil
+			return nil
+		}
+		r.failed(datid)
+		return nil
+	}
+	dsn, err := dsnWithDatabase(r.dsn, datname)
+	if err != nil {
+		r.failed(datid)
+		return nil
+	}
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		r.failed(datid)
+		return nil
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		r.failed(datid)
+		return nil
+	}
+	delete(r.retries, datid)
+	r.conns[datid] = db
+	return db
+}
+
+// failed backs off connecting to the database datid.
+func (r *Resolver) failed(datid int) {
+	rt := r.retries[datid]
+	rt.failures++
+	rt.at = time.Now().Add(backoff(rt.failures))
+	r.retries[datid] = rt
+}
+
+// Close closes the connections to other databases.
+func (r *Resolver) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []string
+	for datid, db := range r.conns {
+		if db == nil {
+			continue
+		}
+		if err := db.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		delete(r.conns, datid)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// dsnWithDatabase returns the dsn that connects to the database dbname.
+func dsnWithDatabase(dsn string, dbname string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		conv, err := pq.ParseURL(dsn)
+		if err != nil {
+			return "", err
+		}
+		dsn = conv
+	}
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(dbname)
+	return strings.TrimSpace(dsn + " dbname='" + value + "'"), nil
+}
+
+// relationName returns the name if resolved, otherwise the OID.
+func relationName(name string, oid int) string {
+	if name != "" {
+		return name
+	}
+	if oid == 0 {
+		return ""
+	}
+	return strconv.Itoa(oid)
+}
+
+// withNames replaces the values of the OID columns with the resolved names.
+func withNames(values []interface{}, columns []string, names map[string]string) []interface{} {
+	for i, c := range columns {
+		if name := names[c]; name != "" && i < len(values) {
+			values[i] = name
+		}
+	}
+	return values
+}
@@ -0,0 +1,135 @@
+package pgsp
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func Test_dsnWithDatabase(t *testing.T) {
+	tests := []struct {
+		name    string
+		dsn     string
+		dbname  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "empty",
+			dsn:    "",
+			dbname: "test",
+			want:   "dbname='test'",
+		},
+		{
+			name:   "keyValue",
+			dsn:    "host=/var/run/postgresql dbname=postgres",
+			dbname: "app",
+			want:   "host=/var/run/postgresql dbname=postgres dbname='app'",
+		},
+		{
+			name:   "quote",
+			dsn:    "host=localhost",
+			dbname: "it's",
+			want:   `host=localhost dbname='it\'s'`,
+		},
+		{
+			name:   "url",
+			dsn:    "postgres://user@localhost:5432/postgres",
+			dbname: "app",
+			want:   "dbname='postgres' host='localhost' port='5432' user='user' dbname='app'",
+		},
+		{
+			name:    "invalidURL",
+			dsn:     "postgres://user@localhost:abc/postgres",
+			dbname:  "app",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dsnWithDatabase(tt.dsn, tt.dbname)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dsnWithDatabase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("dsnWithDatabase() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateIndex_VerticalNames(t *testing.T) {
+	v := CreateIndex{
+		PID:        1,
+		RELID:      16384,
+		IndexRelid: 16390,
+		RelName:    "public.t",
+	}
+	v.columns = []string{"pid", "relid", "index_relid"}
+	want := ` pid         | 1
+ relid       | public.t
+ index_relid | 16390
+`
+	if got := v.Vertical(); got != want {
+		t.Errorf("CreateIndex.Vertical() = %v, want %v", got, want)
+	}
+	if got := v.Relation(); got != "public.t" {
+		t.Errorf("CreateIndex.Relation() = %v, want %v", got, "public.t")
+	}
+}
+
+func TestResolver_DBRetry(t *testing.T) {
+	// Nothing listens on the port.
+	db, err := sqlx.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r := NewResolver("", db)
+	r.datid = 1
+	ctx := context.Background()
+
+	if got := r.DB(ctx, 2); got != nil {
+		t.Fatalf("Resolver.DB() = %v, want nil", got)
+	}
+	if _, ok := r.conns[2]; ok {
+		t.Errorf("Resolver.DB() cached the transient failure")
+	}
+	if rt := r.retries[2]; rt.failures != 1 || rt.at.IsZero() {
+		t.Errorf("Resolver.DB() retry = %+v", rt)
+	}
+	// Not retried while backing off.
+	r.DB(ctx, 2)
+	if rt := r.retries[2]; rt.failures != 1 {
+		t.Errorf("Resolver.DB() retried while backing off: %+v", rt)
+	}
+	r.retries[2] = retry{failures: 1, at: time.Now().Add(-time.Second)}
+	r.DB(ctx, 2)
+	if rt := r.retries[2]; rt.failures != 2 {
+		t.Errorf("Resolver.DB() was not retried after the backoff: %+v", rt)
+	}
+}
+
+func TestResolver_Filenode(t *testing.T) {
+	db := fakeDB(t, map[string]driver.Value{
+		"current_database": int64(1),
+		"relfilenode":      int64(16400),
+	})
+	p := &Pgsp{DB: db, Resolver: NewResolver("", db)}
+	ctx := context.Background()
+	v := Cluster{PID: 10, DATID: 1, RELID: 16384, PHASE: "seq scanning heap"}
+	v.QueryStart = sql.NullTime{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+
+	c := v.Resolve(ctx, p.Resolver).(Cluster)
+	if c.Filenode != 16400 {
+		t.Fatalf("Cluster.Resolve() Filenode = %d, want 16400", c.Filenode)
+	}
+	p.Forget(c)
+	if len(p.Resolver.filenodes) != 0 {
+		t.Errorf("Pgsp.Forget() kept %v", p.Resolver.filenodes)
+	}
+}
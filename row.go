@@ -0,0 +1,321 @@
+package pgsp
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/noborus/pgsp/vertical"
+	"github.com/olekukonko/tablewriter"
+)
+
+// rowType is the column metadata of a progress struct.
+// It is computed once for each type.
+type rowType struct {
+	// columns is the db tags of the fields of the struct in order,
+	// excluding embedded structs.
+	columns []string
+}
+
+var rowTypes sync.Map // reflect.Type -> *rowType
+
+func rowTypeOf(t reflect.Type) *rowType {
+	if rt, ok := rowTypes.Load(t); ok {
+		return rt.(*rowType)
+	}
+	rt := &rowType{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			continue
+		}
+		if tag := field.Tag.Get("db"); tag != "" && tag != "-" {
+			rt.columns = append(rt.columns, tag)
+		}
+	}
+	v, _ := rowTypes.LoadOrStore(t, rt)
+	return v.(*rowType)
+}
+
+// fielder is a row whose columns are accessed by the column names without reflection.
+// The views and Activity implement it with a switch on the db tags.
+type fielder interface {
+	// field returns the pointer to the field of the column,
+	// or nil if the row does not have the column.
+	field(column string) interface{}
+}
+
+// fieldValue returns the value pointed by the pointer returned by field.
+func fieldValue(p interface{}) interface{} {
+	switch p := p.(type) {
+	case *int:
+		return *p
+	case *int64:
+		return *p
+	case *string:
+		return *p
+	case *sql.NullString:
+		return *p
+	case *sql.NullInt64:
+		return *p
+	case *sql.NullInt32:
+		return *p
+	case *sql.NullTime:
+		return *p
+	}
+	return nil
+}
+
+// knownColumns returns the columns of the view that the row has.
+func knownColumns(f fielder, columns []string) []string {
+	known := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if f.field(c) != nil && !sessionColumn(c) {
+			known = append(known, c)
+		}
+	}
+	return known
+}
+
+func sessionColumn(column string) bool {
+	for _, c := range sessionColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// viewColumns is the columns of the view selected for the server version.
+// Each row carries them so that monitors of servers of different versions
+// can run in parallel.
+type viewColumns struct {
+	columns []string
+}
+
+func (c *viewColumns) setColumns(columns []string) {
+	c.columns = columns
+}
+
+// selected returns the columns of the row, or the latest columns if not selected.
+func (c viewColumns) selected(vc VersionColumns) []string {
+	if c.columns == nil {
+		return vc.Latest()
+	}
+	return c.columns
+}
+
+// columnSet is a row of a view that has the columns selected for the server version.
+type columnSet interface {
+	selectedColumns() []string
+}
+
+// ColumnsOf returns the columns of the view selected for the server version of the row,
+// or nil if the progress is not a row of a pg_stat_progress view.
+func ColumnsOf(v Progress) []string {
+	if c, ok := v.(columnSet); ok {
+		return c.selectedColumns()
+	}
+	return nil
+}
+
+// viewRow is a row of a pg_stat_progress view.
+type viewRow interface {
+	columnSet
+	fielder
+	// names returns the resolved names of the OID columns.
+	names() map[string]string
+}
+
+// rowValues returns the values of the selected columns of the row
+// with the OIDs replaced by the resolved names.
+func rowValues(v viewRow) []interface{} {
+	columns := v.selectedColumns()
+	values := make([]interface{}, len(columns))
+	for i, c := range columns {
+		values[i] = fieldValue(v.field(c))
+	}
+	return withNames(values, columns, v.names())
+}
+
+// renderTable renders the row as a table.
+// The columns from split are rendered in a second table if split is not 0,
+// so that the table fits in the terminal.
+func renderTable(v viewRow, split int) string {
+	columns := v.selectedColumns()
+	values := toStrings(rowValues(v))
+	buff := new(bytes.Buffer)
+	if split <= 0 || split >= len(columns) {
+		split = len(columns)
+	}
+	t := tablewriter.NewWriter(buff)
+	t.SetHeader(columns[:split])
+	t.Append(values[:split])
+	t.Render()
+	if split == len(columns) {
+		return buff.String()
+	}
+
+	t2 := tablewriter.NewWriter(buff)
+	t2.SetHeader(columns[split:])
+	t2.Append(values[split:])
+	t2.Render()
+	return buff.String()
+}
+
+// renderVertical renders the row as a vertical table.
+func renderVertical(v viewRow) string {
+	buff := new(bytes.Buffer)
+	vt := vertical.NewWriter(buff)
+	vt.SetHeader(v.selectedColumns())
+	vt.Append(rowValues(v))
+	vt.Render()
+	return buff.String()
+}
+
+// WithColumns returns the row of a pg_stat_progress view with the columns selected
+// for a server version, such as the recorded columns of the row.
+// The columns that the view does not have are ignored.
+func WithColumns(v Progress, columns []string) Progress {
+	w, ok := v.(interface{ withColumns([]string) Progress })
+	if !ok || len(columns) == 0 {
+		return v
+	}
+	return w.withColumns(columns)
+}
+
+// setColumns sets the columns to the row that carries them.
+func setColumns(row interface{}, columns []string) {
+	if c, ok := row.(interface{ setColumns([]string) }); ok && columns != nil {
+		c.setColumns(columns)
+	}
+}
+
+// view is a pg_stat_progress view scanned into T.
+// Each monitor has its own view with the columns of its server version.
+type view[T Progress] struct {
+	name     string
+	versions VersionColumns
+	columns  []string
+	query    string
+}
+
+// newView returns the constructor of the SPTable of the view.
+func newView[T Progress](name string, versions VersionColumns) func() SPTable {
+	return func() SPTable {
+		v := &view[T]{name: name, versions: versions}
+		v.SetVersion(math.MaxInt32)
+		return SPTable{
+			Get:        v.Get,
+			SetVersion: v.SetVersion,
+			Query:      v.Query,
+			Decode:     v.Decode,
+		}
+	}
+}
+
+// SetVersion selects the columns for the server version.
+// Returns false if the view does not exist in the version.
+func (v *view[T]) SetVersion(version int) bool {
+	v.columns = v.versions.Columns(version)
+	if len(v.columns) == 0 {
+		v.query = ""
+		return false
+	}
+	v.query = buildQuery(v.name, v.columns)
+	return true
+}
+
+func (v *view[T]) Query() string {
+	return v.query
+}
+
+func (v *view[T]) Get(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
+	return selectRows[T](ctx, db, v.query, v.columns)
+}
+
+func (v *view[T]) Decode(data []byte) (Progress, error) {
+	var row T
+	if err := decodeRow(data, &row); err != nil {
+		return nil, err
+	}
+	setColumns(&row, v.columns)
+	return row, nil
+}
+
+// getRows returns the rows of the view with the columns of the latest server version.
+func getRows[T Progress](ctx context.Context, db *sqlx.DB, name string, versions VersionColumns) ([]Progress, error) {
+	columns := versions.Latest()
+	return selectRows[T](ctx, db, buildQuery(name, columns), columns)
+}
+
+// selectRows returns the rows of the query scanned into T with the columns.
+func selectRows[T Progress](ctx context.Context, db *sqlx.DB, query string, columns []string) ([]Progress, error) {
+	rows, err := db.QueryxContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var as []Progress
+	for rows.Next() {
+		var row T
+		if err := rows.StructScan(&row); err != nil {
+			return nil, err
+		}
+		setColumns(&row, columns)
+		as = append(as, row)
+	}
+	return as, rows.Err()
+}
+
+// RowOf returns the columns of the progress by the db tag names including the session,
+// with the values that to_json returns for the row (NULL is nil).
+// The columns that the server version does not have are not included.
+// DecodeAs decodes the JSON of the row back into the progress.
+func RowOf(v Progress) map[string]interface{} {
+	if r, ok := v.(interface{ row() map[string]interface{} }); ok {
+		return r.row()
+	}
+	return nil
+}
+
+// rowOf returns the columns and the session of the row.
+func rowOf(f fielder, columns []string) map[string]interface{} {
+	row := make(map[string]interface{}, len(columns)+len(sessionColumns))
+	for _, c := range columns {
+		row[c] = jsonValue(fieldValue(f.field(c)))
+	}
+	for _, c := range sessionColumns {
+		row[c] = jsonValue(fieldValue(f.field(c)))
+	}
+	return row
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case sql.NullString:
+		if t.Valid {
+			return t.String
+		}
+	case sql.NullInt64:
+		if t.Valid {
+			return t.Int64
+		}
+	case sql.NullInt32:
+		if t.Valid {
+			return t.Int32
+		}
+	case sql.NullTime:
+		if t.Valid {
+			return t.Time
+		}
+	default:
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package pgsp
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_rowTypeOf(t *testing.T) {
+	rt := rowTypeOf(reflect.TypeOf(Copy{}))
+	want := []string{
+		"pid", "datid", "datname", "relid", "command", "type",
+		"bytes_processed", "bytes_total", "tuples_processed", "tuples_excluded",
+	}
+	if !reflect.DeepEqual(rt.columns, want) {
+		t.Errorf("rowTypeOf() columns = %v, want %v", rt.columns, want)
+	}
+	if rowTypeOf(reflect.TypeOf(Copy{})) != rt {
+		t.Errorf("rowTypeOf() is not cached")
+	}
+}
+
+// fieldPointers returns the pointers to the fields of the struct pointed by p by the db tags,
+// including embedded structs.
+func fieldPointers(p reflect.Value) map[string]uintptr {
+	ptrs := make(map[string]uintptr)
+	v := p.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			for tag, ptr := range fieldPointers(v.Field(i).Addr()) {
+				ptrs[tag] = ptr
+			}
+			continue
+		}
+		if tag := field.Tag.Get("db"); tag != "" && tag != "-" {
+			ptrs[tag] = v.Field(i).Addr().Pointer()
+		}
+	}
+	return ptrs
+}
+
+func Test_field(t *testing.T) {
+	// The field of each view must return the field of each db tag.
+	for _, f := range []fielder{&Vacuum{}, &Analyze{}, &CreateIndex{}, &Cluster{}, &BaseBackup{}, &Copy{}, &Activity{}} {
+		ptrs := fieldPointers(reflect.ValueOf(f))
+		for tag, want := range ptrs {
+			p := f.field(tag)
+			if p == nil {
+				t.Errorf("%T.field(%q) = nil", f, tag)
+				continue
+			}
+			if got := reflect.ValueOf(p).Pointer(); got != want {
+				t.Errorf("%T.field(%q) is not the field of the tag", f, tag)
+			}
+		}
+		if p := f.field("-"); p != nil {
+			t.Errorf("%T.field(\"-\") = %v", f, p)
+		}
+	}
+	if len(fieldPointers(reflect.ValueOf(&Activity{}))) != len(sessionColumns) {
+		t.Errorf("sessionColumns = %v, want the columns of Activity", sessionColumns)
+	}
+}
+
+func TestWithColumns(t *testing.T) {
+	v := Vacuum{PID: 1}
+	got := WithColumns(v, []string{"pid", "phase", "unknown", "usename"})
+	if want := []string{"pid", "phase"}; !reflect.DeepEqual(ColumnsOf(got), want) {
+		t.Errorf("WithColumns() columns = %v, want %v", ColumnsOf(got), want)
+	}
+	if got.(Vacuum).PID != 1 || v.columns != nil {
+		t.Errorf("WithColumns() = %+v, original %+v", got, v)
+	}
+}
+
+func TestNewMonitor_versions(t *testing.T) {
+	pg16 := NewMonitor()
+	pg16.SetVersion(160000)
+	pg17 := NewMonitor()
+	pg17.SetVersion(170000)
+
+	data := []byte(`{"pid":1,"phase":"scanning heap","max_dead_tuples":10,"num_dead_item_ids":2}`)
+	v16, err := pg16[SPVacuum].Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v17, err := pg17[SPVacuum].Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v16.(Vacuum).columns; !reflect.DeepEqual(got, VacuumVersionColumns[90600]) {
+		t.Errorf("columns of 16 = %v", got)
+	}
+	if got := v17.(Vacuum).columns; !reflect.DeepEqual(got, VacuumVersionColumns[170000]) {
+		t.Errorf("columns of 17 = %v", got)
+	}
+	if pg16[SPVacuum].Query() == pg17[SPVacuum].Query() {
+		t.Errorf("Query() is shared between versions")
+	}
+}
+
+func TestRowOf(t *testing.T) {
+	v := Copy{PID: 1, COMMAND: "COPY FROM", BYTESProcessed: 10, RelName: "public.t"}
+	v.Usename.String, v.Usename.Valid = "postgres", true
+	row := RowOf(v)
+	if row["pid"] != 1 || row["command"] != "COPY FROM" || row["usename"] != "postgres" || row["query"] != nil {
+		t.Errorf("RowOf() = %v", row)
+	}
+	if _, ok := row["-"]; ok {
+		t.Errorf("RowOf() has db:\"-\"")
+	}
+	data, err := json.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeAs[Copy](data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.RelName = ""
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("DecodeAs(RowOf()) = %+v, want %+v", got, v)
+	}
+}
+
+func TestRowOf_version(t *testing.T) {
+	v := Vacuum{PID: 1, PHASE: "scanning heap", MaxDeadTuples: 100}
+	v.setColumns(VacuumVersionColumns.Columns(160000))
+	row := RowOf(v)
+	if row["max_dead_tuples"] != int64(100) || row["usename"] != nil {
+		t.Errorf("RowOf() = %v", row)
+	}
+	if _, ok := row["usename"]; !ok {
+		t.Errorf("RowOf() has no session")
+	}
+	for _, c := range []string{"max_dead_tuple_bytes", "dead_tuple_bytes", "num_dead_item_ids", "indexes_total", "indexes_processed"} {
+		if _, ok := row[c]; ok {
+			t.Errorf("RowOf() of 16 has %s of 17", c)
+		}
+	}
+	if got := ColumnsOf(v); !reflect.DeepEqual(got, VacuumVersionColumns.Columns(160000)) {
+		t.Errorf("ColumnsOf() = %v", got)
+	}
+	if _, ok := RowOf(Vacuum{})["max_dead_tuples"]; ok {
+		t.Errorf("RowOf() without the version has max_dead_tuples, want the latest columns")
+	}
+}
@@ -0,0 +1,244 @@
+package pgsp
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Backoff of the retry of a target after a transient failure.
+var (
+	MinBackoff = time.Second
+	MaxBackoff = time.Minute
+)
+
+// Result is the result of polling the targets.
+type Result struct {
+	Time time.Time
+	// Progress is the progress of the targets that were polled successfully.
+	Progress map[SPTaget][]Progress
+	// Err is the error if the server could not be polled (the connection is lost).
+	Err error
+	// Attempt is the number of the reconnection attempt while the connection is lost.
+	Attempt int
+}
+
+// Due returns the targets to be polled at now.
+// Disabled, unsupported and backing off targets are not polled.
+func (p *Pgsp) Due(now time.Time) []SPTaget {
+	var targets []SPTaget
+	for n, t := range p.StatProgress {
+		if !t.Enable || !t.Supported || now.Before(t.RetryAt) {
+			continue
+		}
+		targets = append(targets, n)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+	return targets
+}
+
+// Poll polls the due targets in one round trip, and the targets without Query and Decode by Get.
+// A target whose view does not exist in the server is no longer polled,
+// and a target that failed with a transient error is retried with backoff.
+// When the connection is lost, Poll reconnects with backoff instead.
+func (p *Pgsp) Poll(ctx context.Context) Result {
+	now := time.Now()
+	if p.lost != nil {
+		if err := p.reconnect(ctx, now); err != nil {
+			return Result{Time: now, Err: err, Attempt: p.attempts}
+		}
+	}
+	result := Result{
+		Time:     now,
+		Progress: make(map[SPTaget][]Progress),
+	}
+	combined, separate := p.combinable(p.Due(now))
+	rows, err := p.collect(ctx, combined)
+	switch {
+	case err == nil:
+		for _, target := range combined {
+			p.StatProgress[target].record(now, nil)
+			result.Progress[target] = rows[target]
+		}
+	case ConnectionLost(err):
+		return p.connectionLost(now, err)
+	default:
+		// Poll each target to find the failed targets.
+		separate = append(separate, combined...)
+	}
+
+	for _, target := range separate {
+		table := p.StatProgress[target]
+		rows, err := p.Get(ctx, target)
+		if ConnectionLost(err) {
+			// Drop the partial result not to finish the operations of the other targets.
+			return p.connectionLost(now, err)
+		}
+		table.record(now, err)
+		if err != nil {
+			continue
+		}
+		result.Progress[target] = rows
+	}
+	return result
+}
+
+// connectionLost schedules the first reconnection.
+func (p *Pgsp) connectionLost(now time.Time, err error) Result {
+	p.closeStmts()
+	p.lost = err
+	p.attempts = 1
+	p.reconnectAt = now.Add(backoff(p.attempts))
+	return Result{Time: now, Err: err, Attempt: p.attempts}
+}
+
+// reconnect checks the connection when the backoff has elapsed
+// and reloads the server version that may have changed by a failover
+// (or that is not known yet if the server was unreachable when opened).
+// It returns the last error while the connection is lost.
+func (p *Pgsp) reconnect(ctx context.Context, now time.Time) error {
+	if now.Before(p.reconnectAt) {
+		return p.lost
+	}
+	version, err := ServerVersion(ctx, p.DB)
+	if err != nil {
+		p.lost = err
+		p.attempts++
+		p.reconnectAt = now.Add(backoff(p.attempts))
+		return err
+	}
+	if version != p.Version {
+		p.Version = version
+		p.StatProgress.SetVersion(version)
+	}
+	p.lost = nil
+	p.attempts = 0
+	return nil
+}
+
+// combinable splits the targets into those that can be combined into one round trip
+// and the others polled by Get.
+func (p *Pgsp) combinable(targets []SPTaget) (combined []SPTaget, separate []SPTaget) {
+	for _, target := range targets {
+		table := p.StatProgress[target]
+		if table.Query == nil || table.Decode == nil {
+			separate = append(separate, target)
+			continue
+		}
+		combined = append(combined, target)
+	}
+	return combined, separate
+}
+
+// collect collects the targets in one round trip.
+func (p *Pgsp) collect(ctx context.Context, targets []SPTaget) (map[SPTaget][]Progress, error) {
+	rows, err := p.Collect(ctx, targets)
+	if err != nil {
+		p.closeStmts()
+		return nil, err
+	}
+	return rows, nil
+}
+
+// record records the result of Get.
+func (t *SPTable) record(now time.Time, err error) {
+	t.Err = err
+	if err == nil {
+		t.Failures = 0
+		t.RetryAt = time.Time{}
+		return
+	}
+	if Unavailable(err) {
+		t.Supported = false
+		t.Enable = false
+		return
+	}
+	t.Failures++
+	t.RetryAt = now.Add(backoff(t.Failures))
+}
+
+// backoff returns the exponential backoff of the number of failures.
+func backoff(failures int) time.Duration {
+	d := MinBackoff
+	for i := 1; i < failures; i++ {
+		d *= 2
+		if d >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return d
+}
+
+// Unavailable returns true if the error means that the view (or its columns)
+// does not exist in the server, which is not retried.
+func Unavailable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "42P01", // undefined_table
+		"42703": // undefined_column
+		return true
+	}
+	return false
+}
+
+// ConnectionLost returns true if the error means that the connection to the server is lost.
+// The targets are not to blame and are not backed off.
+func ConnectionLost(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && !netErr.Timeout() {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code.Class() == "08", // connection_exception
+			pqErr.Code == "57P01", // admin_shutdown
+			pqErr.Code == "57P02", // crash_shutdown
+			pqErr.Code == "57P03": // cannot_connect_now
+			return true
+		}
+	}
+	return false
+}
+
+// unreachable returns true if the error of connecting means that the server cannot be reached for now.
+func unreachable(err error) bool {
+	var netErr net.Error
+	return ConnectionLost(err) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// TargetStatus returns the status of the targets that are not polled normally.
+func (p *Pgsp) TargetStatus(now time.Time) string {
+	var ss []string
+	for n, t := range p.StatProgress {
+		switch {
+		case t.Err != nil && !t.Supported:
+			ss = append(ss, fmt.Sprintf("%s: not available on this server (%s)", n, t.Err))
+		case t.Err != nil && t.Enable:
+			retry := t.RetryAt.Sub(now).Round(time.Second)
+			if retry < 0 {
+				retry = 0
+			}
+			ss = append(ss, fmt.Sprintf("%s: retrying in %s (failure %d): %s", n, retry, t.Failures, t.Err))
+		}
+	}
+	sort.Strings(ss)
+	return strings.Join(ss, "\n")
+}
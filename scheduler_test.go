@@ -0,0 +1,154 @@
+package pgsp
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func Test_backoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: time.Second},
+		{failures: 2, want: 2 * time.Second},
+		{failures: 4, want: 8 * time.Second},
+		{failures: 10, want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestPgsp_Poll(t *testing.T) {
+	get := func(err error) func(context.Context, *sqlx.DB) ([]Progress, error) {
+		return func(context.Context, *sqlx.DB) ([]Progress, error) {
+			if err != nil {
+				return nil, err
+			}
+			return []Progress{Vacuum{PID: 1}}, nil
+		}
+	}
+	p := &Pgsp{
+		StatProgress: StatProgress{
+			SPVacuum:  {Enable: true, Supported: true, Get: get(nil)},
+			SPCopy:    {Enable: true, Supported: true, Get: get(&pq.Error{Code: "42P01"})},
+			SPCluster: {Enable: true, Supported: true, Get: get(errors.New("connection reset by peer"))},
+			SPAnalyze: {Enable: false, Supported: true, Get: get(nil)},
+		},
+	}
+
+	result := p.Poll(context.Background())
+	if len(result.Progress) != 1 || len(result.Progress[SPVacuum]) != 1 {
+		t.Fatalf("Pgsp.Poll() = %v", result.Progress)
+	}
+	if copy := p.StatProgress[SPCopy]; copy.Supported || copy.Enable {
+		t.Errorf("unavailable target is polled: %+v", copy)
+	}
+	cluster := p.StatProgress[SPCluster]
+	if !cluster.Enable || cluster.Failures != 1 || cluster.RetryAt.IsZero() {
+		t.Errorf("transient failure is not retried: %+v", cluster)
+	}
+	due := p.Due(time.Now())
+	if len(due) != 1 || due[0] != SPVacuum {
+		t.Errorf("Pgsp.Due() = %v, want [%v]", due, SPVacuum)
+	}
+	due = p.Due(cluster.RetryAt)
+	if len(due) != 2 {
+		t.Errorf("Pgsp.Due() after backoff = %v", due)
+	}
+}
+
+func TestPgsp_PollConnectionLost(t *testing.T) {
+	p := &Pgsp{
+		StatProgress: StatProgress{
+			SPVacuum: {Enable: true, Supported: true, Get: func(context.Context, *sqlx.DB) ([]Progress, error) {
+				return nil, driver.ErrBadConn
+			}},
+		},
+	}
+	result := p.Poll(context.Background())
+	if result.Err != driver.ErrBadConn || result.Attempt != 1 || result.Progress != nil {
+		t.Fatalf("Pgsp.Poll() = %+v", result)
+	}
+	if vacuum := p.StatProgress[SPVacuum]; vacuum.Failures != 0 || !vacuum.RetryAt.IsZero() {
+		t.Errorf("target is backed off for the lost connection: %+v", vacuum)
+	}
+
+	// Waits for the backoff before reconnecting.
+	result = p.Poll(context.Background())
+	if result.Err != driver.ErrBadConn || result.Attempt != 1 {
+		t.Errorf("Pgsp.Poll() while backing off = %+v", result)
+	}
+}
+
+func TestPgsp_combinable(t *testing.T) {
+	query := func() string { return "SELECT 1" }
+	decode := func([]byte) (Progress, error) { return Vacuum{}, nil }
+	p := &Pgsp{
+		StatProgress: StatProgress{
+			SPVacuum:  {Enable: true, Supported: true, Query: query, Decode: decode},
+			SPAnalyze: {Enable: true, Supported: true, Query: query, Decode: decode},
+			SPCopy:    {Enable: true, Supported: true, Query: query},
+		},
+	}
+	combined, separate := p.combinable(p.Due(time.Now()))
+	if fmt.Sprint(combined) != fmt.Sprint([]SPTaget{SPAnalyze, SPVacuum}) {
+		t.Errorf("Pgsp.combinable() combined = %v", combined)
+	}
+	if fmt.Sprint(separate) != fmt.Sprint([]SPTaget{SPCopy}) {
+		t.Errorf("Pgsp.combinable() separate = %v", separate)
+	}
+}
+
+func TestConnectionLost(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "bad conn", err: driver.ErrBadConn, want: true},
+		{name: "eof", err: fmt.Errorf("query: %w", io.EOF), want: true},
+		{name: "net", err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, want: true},
+		{name: "admin shutdown", err: &pq.Error{Code: "57P01"}, want: true},
+		{name: "connection failure", err: &pq.Error{Code: "08006"}, want: true},
+		{name: "undefined table", err: &pq.Error{Code: "42P01"}, want: false},
+		{name: "other", err: errors.New("connection reset by peer"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConnectionLost(tt.err); got != tt.want {
+				t.Errorf("ConnectionLost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen_unreachable(t *testing.T) {
+	// Nothing listens on the port.
+	p, err := Open("host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer p.DisConnect()
+	p.Targets(nil)
+	result := p.Poll(context.Background())
+	if result.Err == nil || result.Attempt != 1 || result.Progress != nil {
+		t.Errorf("Pgsp.Poll() of unreachable server = %+v", result)
+	}
+	if due := p.Due(time.Now()); len(due) == 0 {
+		t.Errorf("Pgsp.Due() of unreachable server = %v, want the targets to poll after reconnection", due)
+	}
+}
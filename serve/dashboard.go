@@ -0,0 +1,189 @@
+package serve
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/noborus/pgsp"
+	"github.com/noborus/pgsp/output"
+)
+
+//go:embed static
+var static embed.FS
+
+// Dashboard serves a web page that shows the operations like the terminal UI.
+// The page is updated live by the Server-Sent Events of /events.
+// The snapshots are sent by Print.
+type Dashboard struct {
+	mu     sync.Mutex
+	last   []byte
+	subs   map[chan []byte]struct{}
+	closed bool
+	files  http.Handler
+}
+
+// DashboardSnapshot is the data of a snapshot sent to the dashboard.
+type DashboardSnapshot struct {
+	output.Record
+	Operations []DashboardOperation `json:"operations"`
+}
+
+// DashboardOperation is an operation with the details shown by the terminal UI.
+type DashboardOperation struct {
+	output.OperationRecord
+	Phases     []string `json:"phases,omitempty"`
+	PhaseIndex int      `json:"phase_index"`
+	Counters   string   `json:"counters,omitempty"`
+	Estimate   string   `json:"estimate,omitempty"`
+	Session    string   `json:"session"`
+	Query      string   `json:"query,omitempty"`
+	Details    string   `json:"details"`
+	Colors     []string `json:"colors"`
+}
+
+func NewDashboard() *Dashboard {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return &Dashboard{
+		subs:  make(map[chan []byte]struct{}),
+		files: http.FileServer(http.FS(sub)),
+	}
+}
+
+// Print sends the snapshot to the connected pages.
+func (d *Dashboard) Print(s pgsp.Snapshot) error {
+	data, err := json.Marshal(NewDashboardSnapshot(s))
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.last = data
+	for ch := range d.subs {
+		send(ch, data)
+	}
+	return nil
+}
+
+// send sends the data replacing the data not received yet.
+func send(ch chan []byte, data []byte) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- data
+}
+
+// Close disconnects the pages.
+func (d *Dashboard) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	for ch := range d.subs {
+		close(ch)
+		delete(d.subs, ch)
+	}
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/events" {
+		d.events(w, r)
+		return
+	}
+	d.files.ServeHTTP(w, r)
+}
+
+func (d *Dashboard) subscribe() (chan []byte, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, false
+	}
+	ch := make(chan []byte, 1)
+	if d.last != nil {
+		ch <- d.last
+	}
+	d.subs[ch] = struct{}{}
+	return ch, true
+}
+
+func (d *Dashboard) unsubscribe(ch chan []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.subs[ch]; ok {
+		close(ch)
+		delete(d.subs, ch)
+	}
+}
+
+// events streams the snapshots as Server-Sent Events.
+func (d *Dashboard) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch, ok := d.subscribe()
+	if !ok {
+		http.Error(w, "closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer d.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+	for {
+		select {
+		case data, ok := <-ch:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// NewDashboardSnapshot returns the data of the snapshot for the dashboard.
+func NewDashboardSnapshot(s pgsp.Snapshot) DashboardSnapshot {
+	ds := DashboardSnapshot{
+		Record:     output.SnapshotRecord(s),
+		Operations: make([]DashboardOperation, 0, len(s.Operations)),
+	}
+	for _, op := range s.Operations {
+		v := op.Progress
+		st := v.Status()
+		start, end := v.Color()
+		a := v.Session()
+		do := DashboardOperation{
+			OperationRecord: output.NewOperationRecord(op),
+			Phases:          st.Phases,
+			PhaseIndex:      st.Index,
+			Estimate:        op.Estimate(),
+			Session:         a.Summary(),
+			Query:           strings.Join(strings.Fields(a.Query.String), " "),
+			Details:         v.Vertical(),
+			Colors:          []string{start, end},
+		}
+		if st.Indeterminate {
+			do.Counters = st.Counters()
+		}
+		// The row is in details.
+		do.Row = nil
+		ds.Operations = append(ds.Operations, do)
+	}
+	return ds
+}
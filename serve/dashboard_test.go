@@ -0,0 +1,81 @@
+package serve
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+func TestDashboard(t *testing.T) {
+	d := NewDashboard()
+	ts := httptest.NewServer(d)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(page), `new EventSource("events")`) {
+		t.Errorf("Dashboard / is not the page: %s", page)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	v := pgsp.Vacuum{PID: 10, RelName: "public.t", PHASE: "scanning heap", HeapBLKSTotal: 100, HeapBLKSScanned: 10}
+	if err := d.Print(pgsp.Snapshot{
+		Time:       start,
+		Operations: []pgsp.Operation{{ID: 1, Target: pgsp.SPVacuum, Progress: v, Started: start}},
+		Servers:    []pgsp.ServerState{{Targets: "vacuum"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Dashboard /events Content-Type = %q", ct)
+	}
+	r := bufio.NewReader(res.Body)
+	var data string
+	for data == "" {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = strings.TrimPrefix(strings.TrimSpace(line), "data: ")
+		if data == strings.TrimSpace(line) {
+			data = ""
+		}
+	}
+	var got DashboardSnapshot
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Operations) != 1 {
+		t.Fatalf("Dashboard /events operations = %d, want 1", len(got.Operations))
+	}
+	op := got.Operations[0]
+	if op.Relation != "public.t" || op.PhaseIndex != 1 || len(op.Phases) != len(v.Status().Phases) {
+		t.Errorf("Dashboard /events operation = %+v", op)
+	}
+	if !strings.Contains(op.Details, "scanning heap") {
+		t.Errorf("Dashboard /events details = %q", op.Details)
+	}
+
+	// Close ends the stream.
+	d.Close()
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+}
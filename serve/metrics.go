@@ -0,0 +1,283 @@
+// Package serve serves the snapshots of pgsp.Watcher over HTTP.
+package serve
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+// DurationBuckets is the buckets of the histogram of the durations of the finished operations in seconds.
+var DurationBuckets = []float64{1, 10, 60, 300, 900, 1800, 3600, 7200, 21600, 86400}
+
+// Metrics exposes the operations in the Prometheus text format.
+// It is updated with the snapshots by Print.
+type Metrics struct {
+	mu       sync.Mutex
+	snapshot pgsp.Snapshot
+	finished map[finishedKey]*histogram
+	keys     []finishedKey
+}
+
+type finishedKey struct {
+	server  string
+	view    string
+	outcome string
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		finished: make(map[finishedKey]*histogram),
+	}
+}
+
+// Print updates the metrics with the snapshot.
+func (m *Metrics) Print(s pgsp.Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot = s
+	for _, e := range s.Events {
+		f, ok := e.(*pgsp.OperationFinished)
+		if !ok {
+			continue
+		}
+		key := finishedKey{
+			server:  f.Operation.Server,
+			view:    f.Operation.Progress.Name(),
+			outcome: f.Outcome.String(),
+		}
+		h, ok := m.finished[key]
+		if !ok {
+			h = &histogram{buckets: make([]uint64, len(DurationBuckets))}
+			m.finished[key] = h
+			m.keys = append(m.keys, key)
+		}
+		h.observe(f.Duration.Seconds())
+	}
+	return nil
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range DurationBuckets {
+		if v <= b {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// ServeHTTP writes the metrics rendered under the lock after unlocking,
+// so that a slow scraper does not block Print.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	m.mu.Lock()
+	_ = m.write(&buf)
+	m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// operation metrics of each running operation.
+var operationMetrics = []struct {
+	name  string
+	help  string
+	value func(op pgsp.Operation, st pgsp.Status, now time.Time) (float64, bool)
+}{
+	{
+		name: "pgsp_operation_done",
+		help: "Amount of work done in the current phase.",
+		value: func(op pgsp.Operation, st pgsp.Status, now time.Time) (float64, bool) {
+			return float64(st.Counter.Done), true
+		},
+	},
+	{
+		name: "pgsp_operation_total",
+		help: "Total amount of work of the current phase.",
+		value: func(op pgsp.Operation, st pgsp.Status, now time.Time) (float64, bool) {
+			return float64(st.Counter.Total), !st.Indeterminate
+		},
+	},
+	{
+		name:  "pgsp_operation_percent",
+		help:  "Overall completion of the operation in percent.",
+		value: func(op pgsp.Operation, st pgsp.Status, now time.Time) (float64, bool) { return st.Overall * 100, true },
+	},
+	{
+		name:  "pgsp_operation_rate",
+		help:  "Rate of the current phase in units per second.",
+		value: func(op pgsp.Operation, st pgsp.Status, now time.Time) (float64, bool) { return op.Rate, op.HasRate },
+	},
+	{
+		name: "pgsp_operation_phase_eta_seconds",
+		help: "Estimated time remaining of the current phase.",
+		value: func(op pgsp.Operation, st pgsp.Status, now time.Time) (float64, bool) {
+			return op.PhaseETA.Seconds(), op.HasPhaseETA
+		},
+	},
+	{
+		name: "pgsp_operation_duration_seconds",
+		help: "Time the operation has been running.",
+		value: func(op pgsp.Operation, st pgsp.Status, now time.Time) (float64, bool) {
+			return op.Duration(now).Seconds(), true
+		},
+	},
+	{
+		name: "pgsp_operation_start_time_seconds",
+		help: "Time when the operation was first seen since the epoch.",
+		value: func(op pgsp.Operation, st pgsp.Status, now time.Time) (float64, bool) {
+			return float64(op.Started.Unix()), true
+		},
+	},
+}
+
+func (m *Metrics) write(w io.Writer) error {
+	s := m.snapshot
+	var running []pgsp.Operation
+	for _, op := range s.Operations {
+		if op.Running() {
+			running = append(running, op)
+		}
+	}
+	for _, metric := range operationMetrics {
+		if err := header(w, metric.name, metric.help, "gauge"); err != nil {
+			return err
+		}
+		for _, op := range running {
+			st := op.Progress.Status()
+			v, ok := metric.value(op, st, s.Time)
+			if !ok {
+				continue
+			}
+			if err := sample(w, metric.name, operationLabels(op, st), v); err != nil {
+				return err
+			}
+		}
+	}
+	if err := header(w, "pgsp_server_up", "Whether the server could be polled.", "gauge"); err != nil {
+		return err
+	}
+	for _, srv := range s.Servers {
+		up := 1.0
+		if srv.Err != nil {
+			up = 0
+		}
+		if err := sample(w, "pgsp_server_up", labels{{"server", srv.Name}}, up); err != nil {
+			return err
+		}
+	}
+
+	keys := append([]finishedKey(nil), m.keys...)
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.server != b.server {
+			return a.server < b.server
+		}
+		if a.view != b.view {
+			return a.view < b.view
+		}
+		return a.outcome < b.outcome
+	})
+	if err := header(w, "pgsp_operations_finished_total", "Number of the finished operations.", "counter"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := sample(w, "pgsp_operations_finished_total", k.labels(), float64(m.finished[k].count)); err != nil {
+			return err
+		}
+	}
+	const hname = "pgsp_operation_finished_duration_seconds"
+	if err := header(w, hname, "Duration of the finished operations.", "histogram"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		h := m.finished[k]
+		for i, b := range DurationBuckets {
+			ls := append(k.labels(), label{"le", strconv.FormatFloat(b, 'g', -1, 64)})
+			if err := sample(w, hname+"_bucket", ls, float64(h.buckets[i])); err != nil {
+				return err
+			}
+		}
+		if err := sample(w, hname+"_bucket", append(k.labels(), label{"le", "+Inf"}), float64(h.count)); err != nil {
+			return err
+		}
+		if err := sample(w, hname+"_sum", k.labels(), h.sum); err != nil {
+			return err
+		}
+		if err := sample(w, hname+"_count", k.labels(), float64(h.count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+type labels []label
+
+func (k finishedKey) labels() labels {
+	return labels{{"server", k.server}, {"view", k.view}, {"outcome", k.outcome}}
+}
+
+// operationLabels returns the labels of the operation.
+func operationLabels(op pgsp.Operation, st pgsp.Status) labels {
+	v := op.Progress
+	datname, _ := pgsp.RowOf(v)["datname"].(string)
+	return labels{
+		{"server", op.Server},
+		{"view", v.Name()},
+		{"datname", datname},
+		{"relation", v.Relation()},
+		{"phase", st.Phase},
+		{"pid", strconv.Itoa(v.Pid())},
+		{"backend_type", v.Session().BackendType.String},
+		{"unit", st.Counter.Unit},
+	}
+}
+
+func header(w io.Writer, name string, help string, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	return err
+}
+
+func sample(w io.Writer, name string, ls labels, v float64) error {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(ls) > 0 {
+		b.WriteByte('{')
+		for i, l := range ls {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(l.name)
+			b.WriteString(`="`)
+			b.WriteString(labelEscaper.Replace(l.value))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
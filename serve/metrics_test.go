@@ -0,0 +1,94 @@
+package serve
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+func TestMetrics_write(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	v := pgsp.Vacuum{PID: 10, DATNAME: "app", RELID: 1, RelName: `public."t"`, PHASE: "scanning heap", HeapBLKSTotal: 100, HeapBLKSScanned: 10}
+	v.BackendType = sql.NullString{String: "autovacuum worker", Valid: true}
+	running := pgsp.Operation{ID: 1, Server: "primary", Target: pgsp.SPVacuum, Progress: v, Started: start, Rate: 5, HasRate: true}
+	finished := pgsp.Operation{ID: 2, Server: "primary", Target: pgsp.SPVacuum, Progress: pgsp.Vacuum{PID: 11}, Started: start, Finished: start.Add(time.Minute)}
+
+	m := NewMetrics()
+	_ = m.Print(pgsp.Snapshot{
+		Time:       start.Add(2 * time.Hour),
+		Operations: []pgsp.Operation{running, finished},
+		Servers:    []pgsp.ServerState{{Name: "primary"}},
+		Events: []pgsp.Event{&pgsp.OperationFinished{
+			EventInfo: pgsp.EventInfo{Operation: finished, Time: finished.Finished},
+			Duration:  time.Minute,
+			Outcome:   pgsp.OutcomeCompleted,
+		}},
+	})
+	buf := new(bytes.Buffer)
+	if err := m.write(buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	labels := `{server="primary",view="pg_stat_progress_vacuum",datname="app",relation="public.\"t\"",phase="scanning heap",pid="10",backend_type="autovacuum worker",unit="blocks"}`
+	for _, want := range []string{
+		"# TYPE pgsp_operation_done gauge\npgsp_operation_done" + labels + " 10\n",
+		"pgsp_operation_total" + labels + " 100\n",
+		"pgsp_operation_rate" + labels + " 5\n",
+		"pgsp_operation_duration_seconds" + labels + " 7200\n",
+		`pgsp_server_up{server="primary"} 1` + "\n",
+		`pgsp_operations_finished_total{server="primary",view="pg_stat_progress_vacuum",outcome="completed"} 1` + "\n",
+		`pgsp_operation_finished_duration_seconds_bucket{server="primary",view="pg_stat_progress_vacuum",outcome="completed",le="10"} 0` + "\n",
+		`pgsp_operation_finished_duration_seconds_bucket{server="primary",view="pg_stat_progress_vacuum",outcome="completed",le="60"} 1` + "\n",
+		`pgsp_operation_finished_duration_seconds_sum{server="primary",view="pg_stat_progress_vacuum",outcome="completed"} 60` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Metrics.write() does not contain %q\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `pid="11"`) {
+		t.Errorf("Metrics.write() has the finished operation")
+	}
+	if strings.Contains(got, "pgsp_operation_phase_eta_seconds{") {
+		t.Errorf("Metrics.write() has ETA without estimate")
+	}
+}
+
+// blockingWriter blocks in Write until release is closed.
+type blockingWriter struct {
+	header  http.Header
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Header() http.Header { return w.header }
+func (w *blockingWriter) WriteHeader(int)     {}
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	close(w.writing)
+	<-w.release
+	return len(p), nil
+}
+
+func TestMetrics_ServeHTTPSlowClient(t *testing.T) {
+	m := NewMetrics()
+	w := &blockingWriter{header: make(http.Header), writing: make(chan struct{}), release: make(chan struct{})}
+	defer close(w.release)
+	go m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	<-w.writing
+
+	printed := make(chan struct{})
+	go func() {
+		_ = m.Print(pgsp.Snapshot{Time: time.Now()})
+		close(printed)
+	}()
+	select {
+	case <-printed:
+	case <-time.After(time.Second):
+		t.Fatal("Metrics.Print() is blocked by a slow client")
+	}
+}
@@ -3,24 +3,11 @@ package str
 import (
 	"database/sql"
 	"fmt"
-	"reflect"
 	"strconv"
 	"time"
 	"unicode/utf8"
 )
 
-func ToStrStruct(value interface{}) []string {
-	rf := reflect.TypeOf(value)
-	rv := reflect.ValueOf(value)
-
-	num := rf.NumField()
-	row := make([]string, num)
-	for i := 0; i < num; i++ {
-		row[i] = ToStr(rv.Field(i).Interface())
-	}
-	return row
-}
-
 func ToStr(v interface{}) string {
 	switch t := v.(type) {
 	case nil:
@@ -45,6 +32,11 @@ func ToStr(v interface{}) string {
 		return strconv.FormatInt(t.Int64, 10)
 	case time.Time:
 		return t.Format(time.RFC3339)
+	case sql.NullTime:
+		if !t.Valid {
+			return ""
+		}
+		return t.Time.Format(time.RFC3339)
 	}
 	return fmt.Sprint(v)
 }
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
 	"github.com/noborus/pgsp"
 )
 
@@ -16,6 +18,7 @@ var (
 	UpdateInterval    time.Duration
 	AfterCompletion   time.Duration
 	RightMargin       int = 10
+	RateWidth         int = 36
 	MinimumTableWidth int = 120
 	MaxVerticalRows   int = 15
 )
@@ -43,33 +46,71 @@ func tickCmd() tea.Cmd {
 }
 
 type pgrs struct {
-	time time.Time
-	v    pgsp.Progress
-	p    *progress.Model
+	op pgsp.Operation
+	p  *progress.Model
 }
 
 type Model struct {
-	spinC   int
-	pgrss   []pgrs
-	width   int
-	height  int
-	monitor *pgsp.Pgsp
-	status  string
+	spinC     int
+	frame     int
+	pgrss     []pgrs
+	width     int
+	height    int
+	status    string
+	servers   []pgsp.ServerState
+	snapshots <-chan pgsp.Snapshot
+	// cancel stops watching on quit.
+	cancel context.CancelFunc
+	// replay plays the recorded snapshots instead of snapshots.
+	replay *replay
 }
 
 var spin []string = []string{"|", "/", "-", "\\"}
 
+// snapshotMsg is a snapshot sent by the watcher.
+type snapshotMsg pgsp.Snapshot
+
+// watchCmd waits for the next snapshot off the update loop.
+func watchCmd(snapshots <-chan pgsp.Snapshot) tea.Cmd {
+	return func() tea.Msg {
+		s, ok := <-snapshots
+		if !ok {
+			return nil
+		}
+		return snapshotMsg(s)
+	}
+}
+
 func (m Model) Init() tea.Cmd {
-	return tickCmd()
+	if m.replay != nil {
+		return tea.Batch(tickCmd(), m.replay.next())
+	}
+	return tea.Batch(tickCmd(), watchCmd(m.snapshots))
 }
 
 type Option func(*Model) error
 
+// NewModel returns a model that watches the monitor.
 func NewModel(monitor *pgsp.Pgsp) Model {
-	model := Model{
-		monitor: monitor,
+	return NewServersModel([]pgsp.Server{{Collector: monitor}})
+}
+
+// NewServersModel returns a model that watches the servers
+// and groups the operations by server.
+func NewServersModel(servers []pgsp.Server) Model {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := pgsp.NewServersWatcher(servers, UpdateInterval)
+	w.Retention = time.Second * AfterCompletion
+	return NewSnapshotModel(w.Watch(ctx), cancel)
+}
+
+// NewSnapshotModel returns a model that displays the snapshots.
+// cancel is called on quit.
+func NewSnapshotModel(snapshots <-chan pgsp.Snapshot, cancel context.CancelFunc) Model {
+	return Model{
+		snapshots: snapshots,
+		cancel:    cancel,
 	}
-	return model
 }
 
 func NewProgram(m Model, fullScreen bool) *tea.Program {
@@ -81,120 +122,269 @@ func NewProgram(m Model, fullScreen bool) *tea.Program {
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	ctx := context.TODO()
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c", "esc":
+			m.cancel()
 			return m, tea.Quit
-		default:
-			return m, nil
 		}
+		if m.replay != nil {
+			if cmd, ok := m.replayKey(msg.String()); ok {
+				return m, cmd
+			}
+		}
+		return m, nil
 
 	case tea.WindowSizeMsg:
 		m.height = msg.Height
 		m.width = msg.Width
 		for _, pgrs := range m.pgrss {
-			pgrs.p.Width = m.width - RightMargin
+			pgrs.p.Width = m.barWidth()
 		}
 		return m, nil
 
 	case tickMsg:
 		m.spinC++
+		m.frame++
 		if m.spinC > len(spin)-1 {
 			m.spinC = 0
 		}
-		err := m.updateProgress(ctx)
-		if err != nil {
-			fmt.Printf("update error:%v", err)
-		}
 		return m, tickCmd()
+
+	case snapshotMsg:
+		m.updateProgress(pgsp.Snapshot(msg))
+		return m, watchCmd(m.snapshots)
+
+	case replayMsg:
+		return m, m.replayed(msg)
 	}
 	return m, nil
 }
 
 func (m Model) View() string {
 	s := m.status
+	if m.replay != nil {
+		s = m.replay.status() + s
+	}
 	s += "quit: q, ctrl+c, esc\n"
-	num := len(m.pgrss)
-	if num == 0 {
+	if len(m.servers) > 1 {
+		for _, srv := range m.servers {
+			s += serverView(srv)
+			s += m.operationsView(srv.Name)
+		}
+		return s
+	}
+	if len(m.pgrss) == 0 {
 		s = spin[m.spinC] + " " + s
 		return s
 	}
+	return s + m.operationsView("")
+}
 
+// operationsView returns the operations of the server.
+func (m Model) operationsView(server string) string {
+	num := len(m.pgrss)
 	style := lipgloss.NewStyle().
 		Bold(true).
 		Foreground(lipgloss.Color("#FAFAFA")).
 		Background(lipgloss.Color("#7D56F4"))
 
+	var s string
 	for _, pgrs := range m.pgrss {
-		if pgrs.p == nil {
+		if pgrs.p == nil || pgrs.op.Server != server {
 			continue
 		}
-		s += style.Render(pgrs.v.Name()) + "\n"
+		v := pgrs.op.Progress
+		s += style.Render(v.Name())
+		if rel := v.Relation(); rel != "" {
+			s += " " + rel
+		}
+		s += "\n"
+		s += m.sessionView(v)
 		if m.width >= MinimumTableWidth {
-			s += pgrs.v.Table()
+			s += v.Table()
 		} else if num*MaxVerticalRows < m.height {
-			s += pgrs.v.Vertical()
+			s += v.Vertical()
 		}
-		p := pgrs.v.Progress()
-		if p > 0 && p <= 1 {
-			if time.Since(pgrs.time) > time.Second*1 {
-				// Deleted records are considered 100%.
-				s += "\n" + pgrs.p.ViewAs(float64(1))
-				s += " " + time.Since(pgrs.time).Truncate(time.Second).String()
-			} else {
-				s += "\n" + pgrs.p.ViewAs(p)
+		st := v.Status()
+		s += stepper(st) + "\n"
+		switch {
+		case !pgrs.op.Running():
+			s += outcomeView(pgrs, st)
+		case st.Indeterminate:
+			color, _ := v.Color()
+			s += indeterminateBar(m.barWidth(), m.frame, color)
+			s += " " + st.Counters()
+			if rate := pgrs.op.Estimate(); rate != "" {
+				s += " " + rate
 			}
-			s += "\n"
+		default:
+			s += pgrs.p.ViewAs(st.Overall)
+			s += " " + pgrs.op.Estimate()
 		}
+		s += "\n"
 	}
 	return s
 }
 
-func (m *Model) updateProgress(ctx context.Context) error {
-	m.status = fmt.Sprintf("Monitor: %s\n", m.monitor.TargetString())
-
-	for _, table := range m.monitor.StatProgress {
-		result, err := table.Get(ctx, m.monitor.DB)
-		if err != nil {
-			table.Enable = false
-			DebugLog(err)
-			m.status += err.Error() + "\n"
+// updateProgress updates the progress with the snapshot.
+func (m *Model) updateProgress(snapshot pgsp.Snapshot) {
+	m.servers = snapshot.Servers
+	if snapshot.Status != "" {
+		DebugLog(snapshot.Status)
+	}
+	m.status = ""
+	if len(m.servers) <= 1 {
+		m.status = fmt.Sprintf("Monitor: %s\n", snapshot.Targets)
+		for _, srv := range m.servers {
+			if srv.Err != nil {
+				m.status += reconnecting(srv) + "\n"
+			}
 		}
-		for _, v := range result {
-			m.pgrss = m.addProgress(m.pgrss, v)
+		if snapshot.Status != "" {
+			m.status += snapshot.Status + "\n"
 		}
 	}
+	for _, e := range snapshot.Events {
+		DebugLog(e)
+	}
 
-	pgrss := make([]pgrs, 0, len(m.pgrss))
-	for _, pgrs := range m.pgrss {
-		if time.Since(pgrs.time) < time.Second*AfterCompletion {
-			pgrss = append(pgrss, pgrs)
+	bars := make(map[uint64]*progress.Model, len(m.pgrss))
+	for _, pgr := range m.pgrss {
+		bars[pgr.op.ID] = pgr.p
+	}
+	pgrss := make([]pgrs, 0, len(snapshot.Operations))
+	for _, op := range snapshot.Operations {
+		p, ok := bars[op.ID]
+		if !ok {
+			pg := progress.NewModel(
+				progress.WithScaledGradient(op.Progress.Color()),
+				progress.WithWidth(m.barWidth()),
+			)
+			p = &pg
 		}
+		pgrss = append(pgrss, pgrs{op: op, p: p})
 	}
 	m.pgrss = pgrss
-	return nil
 }
 
-func (m Model) addProgress(pgrss []pgrs, v pgsp.Progress) []pgrs {
-	for n, pgr := range pgrss {
-		if pgr.v.Name() == v.Name() && pgr.v.Pid() == v.Pid() {
-			pgrss[n].v = v
-			pgrss[n].time = time.Now()
-			return pgrss
+var serverStyle = lipgloss.NewStyle().Bold(true).Underline(true)
+
+// serverView returns the header of the server with its connection status.
+func serverView(srv pgsp.ServerState) string {
+	s := serverStyle.Render(srv.Name) + " "
+	if srv.Err != nil {
+		s += reconnecting(srv) + "\n"
+		return s
+	}
+	s += outcomeStyles[pgsp.OutcomeCompleted].Render("connected") + " " + srv.Targets + "\n"
+	if srv.Status != "" {
+		s += srv.Status + "\n"
+	}
+	return s
+}
+
+// reconnecting returns the status of the server whose connection is lost.
+func reconnecting(srv pgsp.ServerState) string {
+	s := outcomeStyles[pgsp.OutcomeFailed].Render(fmt.Sprintf("reconnecting (attempt %d)", srv.Attempt))
+	return s + " " + srv.Err.Error()
+}
+
+// stepper returns the phases of the status as "●─◉─○ [2/3] phase 42%".
+func stepper(st pgsp.Status) string {
+	var s string
+	if st.Index >= 0 {
+		steps := make([]string, len(st.Phases))
+		for i := range st.Phases {
+			switch {
+			case i < st.Index:
+				steps[i] = "●"
+			case i == st.Index:
+				steps[i] = "◉"
+			default:
+				steps[i] = "○"
+			}
 		}
+		s = strings.Join(steps, "─")
+		s += fmt.Sprintf(" [%d/%d] ", st.Index+1, len(st.Phases))
 	}
+	s += st.Phase
+	if st.Counter.Total > 0 {
+		s += fmt.Sprintf(" %.0f%%", st.PhaseProgress*100)
+	}
+	return s
+}
+
+var outcomeStyles = map[pgsp.Outcome]lipgloss.Style{
+	pgsp.OutcomeUnknown:   lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")),
+	pgsp.OutcomeCompleted: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#04B575")),
+	pgsp.OutcomeFailed:    lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF4672")),
+	pgsp.OutcomeCancelled: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FFB000")),
+}
+
+// outcomeView returns the bar of the operation that disappeared with its outcome.
+func outcomeView(pgrs pgrs, st pgsp.Status) string {
+	var s string
+	label := pgrs.op.Outcome.String()
+	switch pgrs.op.Outcome {
+	case pgsp.OutcomeCompleted:
+		s = pgrs.p.ViewAs(float64(1))
+	case pgsp.OutcomeUnknown:
+		s = pgrs.p.ViewAs(st.Overall)
+		label = "finishing"
+	default:
+		s = pgrs.p.ViewAs(st.Overall)
+	}
+	s += " " + outcomeStyles[pgrs.op.Outcome].Render(label)
+	s += " " + pgrs.op.Duration(time.Now()).Truncate(time.Second).String()
+	return s
+}
+
+// indeterminateBar returns a bar with a block moving for each frame
+// for progress whose total is unknown.
+func indeterminateBar(width int, frame int, color string) string {
+	// Leave room for the percentage like progress.Model.
+	width -= 5
+	if width <= 0 {
+		return ""
+	}
+	size := width / 5
+	if size < 1 {
+		size = 1
+	}
+	pos := frame%(width+size) - size
+	full := lipgloss.NewStyle().Foreground(lipgloss.Color(color))
+	var b strings.Builder
+	for i := 0; i < width; i++ {
+		if i >= pos && i < pos+size {
+			b.WriteString(full.Render("█"))
+		} else {
+			b.WriteString("░")
+		}
+	}
+	return b.String()
+}
+
+// barWidth returns the width of the progress bar leaving room for the rate and phase ETA.
+func (m Model) barWidth() int {
+	return m.width - RightMargin - RateWidth
+}
+
+// sessionView returns the session of the backend of the progress.
+func (m Model) sessionView(v pgsp.Progress) string {
+	a := v.Session()
+	s := fmt.Sprintf("pid %d %s\n", v.Pid(), a.Summary())
+	if a.Query.String != "" && m.width >= MinimumTableWidth {
+		query := strings.Join(strings.Fields(a.Query.String), " ")
+		s += truncate(query, m.width-RightMargin) + "\n"
+	}
+	return s
+}
 
-	pg := progress.NewModel(
-		progress.WithScaledGradient(v.Color()),
-		progress.WithWidth(m.width-RightMargin),
-	)
-	pgrs := pgrs{
-		time: time.Now(),
-		v:    v,
-		p:    &pg,
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
 	}
-	pgrss = append(pgrss, pgrs)
-	return pgrss
+	return runewidth.Truncate(s, width, "...")
 }
@@ -0,0 +1,142 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/noborus/pgsp"
+)
+
+// Settings of the replay.
+var (
+	// ReplaySeek is the time to seek by the left and right keys.
+	ReplaySeek = 10 * time.Second
+	// MinReplaySpeed and MaxReplaySpeed are the range of the speed of the replay.
+	MinReplaySpeed = 1.0 / 64
+	MaxReplaySpeed = 1024.0
+)
+
+// replay plays the recorded snapshots at the speed.
+type replay struct {
+	snapshots []pgsp.Snapshot
+	index     int
+	speed     float64
+	paused    bool
+	// gen invalidates the scheduled snapshot on pause, seek and speed change.
+	gen int
+}
+
+// replayMsg shows the next snapshot scheduled in the generation.
+type replayMsg struct {
+	gen int
+}
+
+// NewReplayModel returns a model that plays the recorded snapshots at the speed.
+// space pauses, left and right seek, + and - change the speed.
+func NewReplayModel(snapshots []pgsp.Snapshot, speed float64) Model {
+	if speed <= 0 {
+		speed = 1
+	}
+	m := NewSnapshotModel(nil, func() {})
+	m.replay = &replay{snapshots: snapshots}
+	m.replay.setSpeed(speed)
+	if len(snapshots) > 0 {
+		m.updateProgress(snapshots[0])
+	}
+	return m
+}
+
+// next schedules the next snapshot after the recorded interval divided by the speed.
+func (r *replay) next() tea.Cmd {
+	if r.paused || r.index+1 >= len(r.snapshots) {
+		return nil
+	}
+	d := r.snapshots[r.index+1].Time.Sub(r.snapshots[r.index].Time)
+	d = time.Duration(float64(d) / r.speed)
+	gen := r.gen
+	return tea.Tick(d, func(time.Time) tea.Msg {
+		return replayMsg{gen: gen}
+	})
+}
+
+// setSpeed sets the speed within MinReplaySpeed and MaxReplaySpeed.
+func (r *replay) setSpeed(speed float64) {
+	switch {
+	case speed < MinReplaySpeed:
+		speed = MinReplaySpeed
+	case speed > MaxReplaySpeed:
+		speed = MaxReplaySpeed
+	}
+	r.speed = speed
+}
+
+// seek moves to the last snapshot at the time d from the current snapshot.
+func (r *replay) seek(d time.Duration) {
+	t := r.snapshots[r.index].Time.Add(d)
+	i := sort.Search(len(r.snapshots), func(i int) bool {
+		return r.snapshots[i].Time.After(t)
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+	if d > 0 && i <= r.index && r.index+1 < len(r.snapshots) {
+		i = r.index + 1
+	}
+	r.index = i
+}
+
+// replayKey handles the controls of the replay.
+// Returns false if the key is not a control.
+func (m *Model) replayKey(key string) (tea.Cmd, bool) {
+	r := m.replay
+	if len(r.snapshots) == 0 {
+		return nil, false
+	}
+	switch key {
+	case " ", "space", "p":
+		r.paused = !r.paused
+	case "right", "l":
+		r.seek(ReplaySeek)
+	case "left", "h":
+		r.seek(-ReplaySeek)
+	case "+", "up":
+		r.setSpeed(r.speed * 2)
+	case "-", "down":
+		r.setSpeed(r.speed / 2)
+	default:
+		return nil, false
+	}
+	r.gen++
+	m.updateProgress(r.snapshots[r.index])
+	return r.next(), true
+}
+
+// replayed shows the scheduled snapshot.
+func (m *Model) replayed(msg replayMsg) tea.Cmd {
+	r := m.replay
+	if msg.gen != r.gen || r.index+1 >= len(r.snapshots) {
+		return nil
+	}
+	r.index++
+	m.updateProgress(r.snapshots[r.index])
+	return r.next()
+}
+
+// status returns the position and the speed of the replay.
+func (r *replay) status() string {
+	if len(r.snapshots) == 0 {
+		return "Replay: no snapshots\n"
+	}
+	s := fmt.Sprintf("Replay: %s [%d/%d] %gx",
+		r.snapshots[r.index].Time.Local().Format("2006-01-02 15:04:05"),
+		r.index+1, len(r.snapshots), r.speed)
+	switch {
+	case r.paused:
+		s += " paused"
+	case r.index+1 >= len(r.snapshots):
+		s += " end"
+	}
+	return s + "\npause: space, seek: ←/→, speed: +/-\n"
+}
@@ -0,0 +1,143 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/noborus/pgsp"
+)
+
+// snapshotsAt returns the snapshots at the seconds.
+func snapshotsAt(seconds ...int) []pgsp.Snapshot {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshots := make([]pgsp.Snapshot, len(seconds))
+	for i, sec := range seconds {
+		snapshots[i] = pgsp.Snapshot{Time: start.Add(time.Duration(sec) * time.Second)}
+	}
+	return snapshots
+}
+
+func Test_replay_seek(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshots []pgsp.Snapshot
+		index     int
+		d         time.Duration
+		want      int
+	}{
+		{
+			name:      "forward",
+			snapshots: snapshotsAt(0, 5, 10, 15, 20),
+			index:     0,
+			d:         10 * time.Second,
+			want:      2,
+		},
+		{
+			name:      "backward",
+			snapshots: snapshotsAt(0, 5, 10, 15, 20),
+			index:     4,
+			d:         -10 * time.Second,
+			want:      2,
+		},
+		{
+			name:      "beforeStart",
+			snapshots: snapshotsAt(0, 5, 10),
+			index:     1,
+			d:         -10 * time.Second,
+			want:      0,
+		},
+		{
+			name:      "afterEnd",
+			snapshots: snapshotsAt(0, 5, 10),
+			index:     1,
+			d:         time.Minute,
+			want:      2,
+		},
+		{
+			name:      "gap",
+			snapshots: snapshotsAt(0, 60, 65),
+			index:     0,
+			d:         10 * time.Second,
+			want:      1,
+		},
+		{
+			name:      "end",
+			snapshots: snapshotsAt(0, 5),
+			index:     1,
+			d:         10 * time.Second,
+			want:      1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &replay{snapshots: tt.snapshots, index: tt.index, speed: 1}
+			r.seek(tt.d)
+			if r.index != tt.want {
+				t.Errorf("replay.seek() index = %d, want %d", r.index, tt.want)
+			}
+		})
+	}
+}
+
+func TestModel_replayKey(t *testing.T) {
+	m := NewReplayModel(snapshotsAt(0, 5, 10, 15, 20), 1)
+	r := m.replay
+
+	// Pause.
+	if cmd, ok := m.replayKey(" "); !ok || cmd != nil || !r.paused {
+		t.Errorf("replayKey(space) = %v, %v, paused %v", cmd, ok, r.paused)
+	}
+	// The snapshot scheduled before the pause is not shown.
+	if cmd := m.replayed(replayMsg{gen: r.gen - 1}); cmd != nil || r.index != 0 {
+		t.Errorf("replayed() of the old generation moved to %d", r.index)
+	}
+	// Seek while paused.
+	if cmd, ok := m.replayKey("right"); !ok || cmd != nil || r.index != 2 {
+		t.Errorf("replayKey(right) = %v, %v, index %d", cmd, ok, r.index)
+	}
+	// Resume.
+	if cmd, ok := m.replayKey("p"); !ok || cmd == nil || r.paused {
+		t.Errorf("replayKey(p) = %v, %v, paused %v", cmd, ok, r.paused)
+	}
+	if cmd := m.replayed(replayMsg{gen: r.gen}); cmd == nil || r.index != 3 {
+		t.Errorf("replayed() = %v, index %d, want 3", cmd, r.index)
+	}
+	if _, ok := m.replayKey("x"); ok {
+		t.Errorf("replayKey(x) is a control")
+	}
+
+	// Speed.
+	for i := 0; i < 20; i++ {
+		m.replayKey("+")
+	}
+	if r.speed != MaxReplaySpeed {
+		t.Errorf("replay speed = %v, want %v", r.speed, MaxReplaySpeed)
+	}
+	for i := 0; i < 40; i++ {
+		m.replayKey("-")
+	}
+	if r.speed != MinReplaySpeed {
+		t.Errorf("replay speed = %v, want %v", r.speed, MinReplaySpeed)
+	}
+	m.replayKey("+")
+	if r.speed != MinReplaySpeed*2 {
+		t.Errorf("replay speed = %v, want %v", r.speed, MinReplaySpeed*2)
+	}
+}
+
+func TestNewReplayModel_speed(t *testing.T) {
+	tests := []struct {
+		speed float64
+		want  float64
+	}{
+		{speed: 0, want: 1},
+		{speed: 10, want: 10},
+		{speed: 1e6, want: MaxReplaySpeed},
+		{speed: 1e-6, want: MinReplaySpeed},
+	}
+	for _, tt := range tests {
+		if got := NewReplayModel(snapshotsAt(0), tt.speed).replay.speed; got != tt.want {
+			t.Errorf("NewReplayModel(%v) speed = %v, want %v", tt.speed, got, tt.want)
+		}
+	}
+}
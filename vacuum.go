@@ -1,14 +1,10 @@
 package pgsp
 
 import (
-	"bytes"
 	"context"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
-	"github.com/noborus/pgsp/str"
-	"github.com/noborus/pgsp/vertical"
-	"github.com/olekukonko/tablewriter"
 )
 
 // pg_stat_progress_vacuum
@@ -24,41 +20,41 @@ type Vacuum struct {
 	IndexVacuumCount int64  `db:"index_vacuum_count"`
 	MaxDeadTuples    int64  `db:"max_dead_tuples"`
 	NumDeadTuples    int64  `db:"num_dead_tuples"`
+	// PostgreSQL 17 or later.
+	MaxDeadTupleBytes int64 `db:"max_dead_tuple_bytes"`
+	DeadTupleBytes    int64 `db:"dead_tuple_bytes"`
+	NumDeadItemIDs    int64 `db:"num_dead_item_ids"`
+	IndexesTotal      int64 `db:"indexes_total"`
+	IndexesProcessed  int64 `db:"indexes_processed"`
+	// Resolved names of the OIDs.
+	RelName string `db:"-"`
+	// Session of the backend.
+	Activity
+	// Columns selected for the server version.
+	viewColumns
 }
 
-var (
-	VacuumTableName = "pg_stat_progress_vacuum"
-	VacuumQuery     string
-	VacuumColumns   []string
-)
+var VacuumTableName = "pg_stat_progress_vacuum"
 
-func GetVacuum(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
-	if len(VacuumColumns) == 0 {
-		VacuumColumns = getColumns(Vacuum{})
-	}
-	if VacuumQuery == "" {
-		VacuumQuery = buildQuery(VacuumTableName, VacuumColumns)
-	}
-	return selectVacuum(ctx, db, VacuumQuery)
+// VacuumVersionColumns is the columns of pg_stat_progress_vacuum for each server version.
+var VacuumVersionColumns = VersionColumns{
+	90600: {
+		"pid", "datid", "datname", "relid", "phase",
+		"heap_blks_total", "heap_blks_scanned", "heap_blks_vacuumed", "index_vacuum_count",
+		"max_dead_tuples", "num_dead_tuples",
+	},
+	170000: {
+		"pid", "datid", "datname", "relid", "phase",
+		"heap_blks_total", "heap_blks_scanned", "heap_blks_vacuumed", "index_vacuum_count",
+		"max_dead_tuple_bytes", "dead_tuple_bytes", "num_dead_item_ids",
+		"indexes_total", "indexes_processed",
+	},
 }
 
-func selectVacuum(ctx context.Context, db *sqlx.DB, query string) ([]Progress, error) {
-	rows, err := db.QueryxContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var as []Progress
-	for rows.Next() {
-		var row Vacuum
-		err = rows.StructScan(&row)
-		if err != nil {
-			return nil, err
-		}
-		as = append(as, row)
-	}
-	return as, nil
+// GetVacuum returns the rows of pg_stat_progress_vacuum with the columns of the latest server version.
+// Pgsp.Get selects the columns of the connected server.
+func GetVacuum(ctx context.Context, db *sqlx.DB) ([]Progress, error) {
+	return getRows[Vacuum](ctx, db, VacuumTableName, VacuumVersionColumns)
 }
 
 func (v Vacuum) Name() string {
@@ -69,34 +65,127 @@ func (v Vacuum) Pid() int {
 	return v.PID
 }
 
+func (v Vacuum) Relation() string {
+	return relationName(v.RelName, v.RELID)
+}
+
+func (v Vacuum) RelationID() (int, int) {
+	return v.DATID, v.RELID
+}
+
+func (v Vacuum) Resolve(ctx context.Context, r *Resolver) Progress {
+	v.RelName = r.Name(ctx, v.DATID, v.RELID)
+	return v
+}
+
 func (v Vacuum) Color() (string, string) {
 	return "#5A56E0", "#FF7CCB"
 }
 
 func (v Vacuum) Table() string {
-	value := str.ToStrStruct(v)
-	buff := new(bytes.Buffer)
-	t := tablewriter.NewWriter(buff)
-	t.SetHeader(VacuumColumns[0:7])
-	t.Append(value[0:7])
-	t.Render()
-
-	t2 := tablewriter.NewWriter(buff)
-	t2.SetHeader(VacuumColumns[7:])
-	t2.Append(value[7:])
-	t2.Render()
-	return buff.String()
+	return renderTable(&v, 7)
 }
 
 func (v Vacuum) Vertical() string {
-	buff := new(bytes.Buffer)
-	vt := vertical.NewWriter(buff)
-	vt.SetHeader(VacuumColumns)
-	vt.AppendStruct(v)
-	vt.Render()
-	return buff.String()
+	return renderVertical(&v)
+}
+
+// selectedColumns returns the columns of the view selected for the server version.
+func (v Vacuum) selectedColumns() []string {
+	return v.selected(VacuumVersionColumns)
+}
+
+// names returns the resolved names of the OID columns.
+func (v Vacuum) names() map[string]string {
+	return map[string]string{
+		"relid": v.RelName,
+	}
+}
+
+// field returns the pointer to the field of the column.
+func (v *Vacuum) field(column string) interface{} {
+	switch column {
+	case "pid":
+		return &v.PID
+	case "datid":
+		return &v.DATID
+	case "datname":
+		return &v.DATNAME
+	case "relid":
+		return &v.RELID
+	case "phase":
+		return &v.PHASE
+	case "heap_blks_total":
+		return &v.HeapBLKSTotal
+	case "heap_blks_scanned":
+		return &v.HeapBLKSScanned
+	case "heap_blks_vacuumed":
+		return &v.HeapBLKSVacuumed
+	case "index_vacuum_count":
+		return &v.IndexVacuumCount
+	case "max_dead_tuples":
+		return &v.MaxDeadTuples
+	case "num_dead_tuples":
+		return &v.NumDeadTuples
+	case "max_dead_tuple_bytes":
+		return &v.MaxDeadTupleBytes
+	case "dead_tuple_bytes":
+		return &v.DeadTupleBytes
+	case "num_dead_item_ids":
+		return &v.NumDeadItemIDs
+	case "indexes_total":
+		return &v.IndexesTotal
+	case "indexes_processed":
+		return &v.IndexesProcessed
+	}
+	return v.Activity.field(column)
+}
+
+// row returns the selected columns and the session by the column names.
+func (v Vacuum) row() map[string]interface{} {
+	return rowOf(&v, v.selectedColumns())
+}
+
+// withColumns returns the row with the columns selected for a server version.
+func (v Vacuum) withColumns(columns []string) Progress {
+	v.columns = knownColumns(&v, columns)
+	return v
+}
+
+func (v Vacuum) Phase() string {
+	return v.PHASE
+}
+
+func (v Vacuum) Counter() Counter {
+	switch v.PHASE {
+	case "scanning heap":
+		return Counter{Done: v.HeapBLKSScanned, Total: v.HeapBLKSTotal, Unit: UnitBlocks}
+	case "vacuuming heap":
+		return Counter{Done: v.HeapBLKSVacuumed, Total: v.HeapBLKSTotal, Unit: UnitBlocks}
+	case "vacuuming indexes", "cleaning up indexes":
+		// indexes_total is available in PostgreSQL 17 or later.
+		return Counter{Done: v.IndexesProcessed, Total: v.IndexesTotal, Unit: UnitIndexes}
+	}
+	return Counter{}
+}
+
+// Status returns the status whose overall completion is based on the heap scan.
+// VACUUM returns to "scanning heap" after vacuuming the indexes and the heap
+// whenever the memory for the dead tuples fills up, so the index passes before
+// the end of the scan hold the overall completion, and only the last pass
+// after the scan has its weight.
+func (v Vacuum) Status() Status {
+	s := VacuumPhases.Status(v.PHASE, v.Counter())
+	if s.Index < 0 || s.Index >= VacuumPhases.Index("cleaning up indexes") {
+		return s
+	}
+	scan := Counter{Done: v.HeapBLKSScanned, Total: v.HeapBLKSTotal}
+	if s.Index <= VacuumPhases.Index("scanning heap") || scan.Progress() < 1 {
+		s.Overall = VacuumPhases.overall(VacuumPhases.Index("scanning heap"), scan.Progress())
+	}
+	return s
 }
 
 func (v Vacuum) Progress() float64 {
-	return float64(v.HeapBLKSScanned) / float64(v.HeapBLKSTotal)
+	return v.Status().Overall
 }
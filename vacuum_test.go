@@ -0,0 +1,36 @@
+package pgsp
+
+import "testing"
+
+func TestVacuum_Status(t *testing.T) {
+	// The progress of VACUUM must not go backwards when it returns to scanning the heap
+	// after an index pass.
+	rows := []Vacuum{
+		{PHASE: "initializing"},
+		{PHASE: "scanning heap", HeapBLKSTotal: 100, HeapBLKSScanned: 20},
+		{PHASE: "scanning heap", HeapBLKSTotal: 100, HeapBLKSScanned: 40},
+		{PHASE: "vacuuming indexes", HeapBLKSTotal: 100, HeapBLKSScanned: 40},
+		{PHASE: "vacuuming indexes", HeapBLKSTotal: 100, HeapBLKSScanned: 40, IndexesTotal: 2, IndexesProcessed: 1},
+		{PHASE: "vacuuming heap", HeapBLKSTotal: 100, HeapBLKSScanned: 40, HeapBLKSVacuumed: 30},
+		{PHASE: "scanning heap", HeapBLKSTotal: 100, HeapBLKSScanned: 45, HeapBLKSVacuumed: 30, IndexVacuumCount: 1},
+		{PHASE: "scanning heap", HeapBLKSTotal: 100, HeapBLKSScanned: 100, HeapBLKSVacuumed: 30, IndexVacuumCount: 1},
+		{PHASE: "vacuuming indexes", HeapBLKSTotal: 100, HeapBLKSScanned: 100, HeapBLKSVacuumed: 30, IndexVacuumCount: 1},
+		{PHASE: "vacuuming heap", HeapBLKSTotal: 100, HeapBLKSScanned: 100, HeapBLKSVacuumed: 30, IndexVacuumCount: 2},
+		{PHASE: "vacuuming heap", HeapBLKSTotal: 100, HeapBLKSScanned: 100, HeapBLKSVacuumed: 100, IndexVacuumCount: 2},
+		{PHASE: "cleaning up indexes", HeapBLKSTotal: 100, HeapBLKSScanned: 100, HeapBLKSVacuumed: 100, IndexVacuumCount: 2},
+		{PHASE: "truncating heap", HeapBLKSTotal: 100, HeapBLKSScanned: 100, HeapBLKSVacuumed: 100, IndexVacuumCount: 2},
+		{PHASE: "performing final cleanup", HeapBLKSTotal: 100, HeapBLKSScanned: 100, HeapBLKSVacuumed: 100, IndexVacuumCount: 2},
+	}
+	prev := -1.0
+	for _, v := range rows {
+		p := v.Progress()
+		if p < prev {
+			t.Errorf("Vacuum.Progress() = %v at %q %v, went back from %v", p, v.PHASE, v.Counter(), prev)
+		}
+		prev = p
+	}
+	// The index pass before the end of the scan holds the completion of the scan.
+	if got, want := rows[3].Progress(), rows[2].Progress(); got != want {
+		t.Errorf("Vacuum.Progress() = %v at the index pass, want %v", got, want)
+	}
+}
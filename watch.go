@@ -0,0 +1,448 @@
+package pgsp
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collector is the source of the rows polled by Watcher.
+// Pgsp collects from the PostgreSQL server.
+type Collector interface {
+	// Poll polls the due targets.
+	Poll(ctx context.Context) Result
+	// Outcome determines the outcome of an operation that disappeared.
+	Outcome(ctx context.Context, v Progress, vanished time.Time) Outcome
+	// TargetString returns the monitored targets.
+	TargetString() string
+	// TargetStatus returns the status of the targets that are not polled normally.
+	TargetStatus(now time.Time) string
+}
+
+// Forgetter is implemented by Collector that keeps what it has seen of an operation
+// to determine its outcome.
+type Forgetter interface {
+	// Forget drops what is kept for the operation v whose outcome has been reported.
+	Forget(v Progress)
+}
+
+// Server is a named Collector watched with other servers.
+type Server struct {
+	Name      string
+	Collector Collector
+}
+
+// Operation is an operation tracked across polls.
+type Operation struct {
+	// ID identifies the operation in a Watcher.
+	ID uint64
+	// Server is the name of the server of the operation.
+	Server   string
+	Target   SPTaget
+	Progress Progress
+	// Started is the time when the operation was first seen.
+	Started time.Time
+	// LastSeen is the time when the operation was last seen.
+	LastSeen time.Time
+	// Finished is the time when the operation disappeared, zero while running.
+	Finished time.Time
+	Outcome  Outcome
+
+	// Rate is the smoothed rate of the current phase in units per second.
+	Rate    float64
+	HasRate bool
+	// PhaseETA is the estimated time remaining of the current phase.
+	PhaseETA    time.Duration
+	HasPhaseETA bool
+}
+
+// Running returns true if the operation has not disappeared.
+func (o Operation) Running() bool {
+	return o.Finished.IsZero()
+}
+
+// Duration returns the time from the start to the end (or now).
+func (o Operation) Duration(now time.Time) time.Duration {
+	if !o.Running() {
+		now = o.Finished
+	}
+	return now.Sub(o.Started)
+}
+
+// Estimate returns the rate and the ETA of the current phase as "rate/s phase ETA duration".
+func (o Operation) Estimate() string {
+	return formatEstimate(o.Rate, o.HasRate, o.PhaseETA, o.HasPhaseETA, o.Progress.Counter().Unit)
+}
+
+// Snapshot is the state of the operations after a poll.
+type Snapshot struct {
+	Time       time.Time
+	Operations []Operation
+	// Targets is the monitored targets.
+	Targets string
+	// Status is the status of the targets that are not polled normally.
+	Status string
+	// Servers is the state of each server in the order of Watcher.Servers.
+	Servers []ServerState
+	// Events is the lifecycle events since the previous snapshot.
+	Events []Event
+}
+
+// ServerState is the state of a server in a snapshot.
+type ServerState struct {
+	Name string
+	// Targets is the monitored targets.
+	Targets string
+	// Status is the status of the targets that are not polled normally.
+	Status string
+	// Err is the error of the last poll if the server could not be polled.
+	Err error
+	// Attempt is the number of the reconnection attempt while Err is set.
+	Attempt int
+}
+
+type opKey struct {
+	server string
+	name   string
+	pid    int
+}
+
+func keyOf(server string, v Progress) opKey {
+	return opKey{server: server, name: v.Name(), pid: v.Pid()}
+}
+
+type operation struct {
+	Operation
+	est Estimator
+
+	// State of the events.
+	lastChange time.Time
+	step       int
+	stalled    bool
+	reported   bool
+}
+
+// Watcher polls the servers periodically and tracks the lifecycle of the operations
+// by diffing successive results keyed by the server, the view name and pid.
+type Watcher struct {
+	// Servers is polled concurrently.
+	Servers  []Server
+	Interval time.Duration
+	// Timeout is the timeout of a poll. The default is derived from Interval.
+	Timeout time.Duration
+	// Retention is the time to keep finished operations.
+	Retention time.Duration
+
+	lastID  uint64
+	ops     []*operation
+	running map[opKey]*operation
+	events  []Event
+	lost    []Result
+}
+
+// Default settings of Watcher.
+var (
+	DefaultRetention   = 10 * time.Second
+	PollTimeoutFactor  = 4
+	MinPollTimeout     = 2 * time.Second
+	DefaultWatchPeriod = 500 * time.Millisecond
+)
+
+// NewWatcher returns a Watcher of a single unnamed server.
+func NewWatcher(c Collector, interval time.Duration) *Watcher {
+	return NewServersWatcher([]Server{{Collector: c}}, interval)
+}
+
+// NewServersWatcher returns a Watcher of the servers.
+func NewServersWatcher(servers []Server, interval time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultWatchPeriod
+	}
+	return &Watcher{
+		Servers:   servers,
+		Interval:  interval,
+		Retention: DefaultRetention,
+		running:   make(map[opKey]*operation),
+	}
+}
+
+// Watch polls the server every interval and sends the snapshots to the returned channel.
+// The channel is closed when ctx is done.
+func (p *Pgsp) Watch(ctx context.Context, interval time.Duration) <-chan Snapshot {
+	return NewWatcher(p, interval).Watch(ctx)
+}
+
+// Watch polls every Interval and sends the snapshots to the returned channel.
+// The channel is closed when ctx is done.
+func (w *Watcher) Watch(ctx context.Context) <-chan Snapshot {
+	ch := make(chan Snapshot, 1)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+		for {
+			snapshot := w.Poll(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case ch <- snapshot:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// timeout returns the timeout of a poll.
+func (w *Watcher) timeout() time.Duration {
+	if w.Timeout > 0 {
+		return w.Timeout
+	}
+	t := w.Interval * time.Duration(PollTimeoutFactor)
+	if t < MinPollTimeout {
+		t = MinPollTimeout
+	}
+	return t
+}
+
+// Poll polls the servers concurrently once and returns the snapshot.
+func (w *Watcher) Poll(ctx context.Context) Snapshot {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout())
+	defer cancel()
+	results := make([]Result, len(w.Servers))
+	var wg sync.WaitGroup
+	for i, s := range w.Servers {
+		wg.Add(1)
+		go func(i int, c Collector) {
+			defer wg.Done()
+			results[i] = c.Poll(ctx)
+		}(i, s.Collector)
+	}
+	wg.Wait()
+
+	var now time.Time
+	w.lost = make([]Result, len(w.Servers))
+	for i, result := range results {
+		if result.Err != nil {
+			w.lost[i] = result
+		}
+		w.apply(w.Servers[i].Name, result)
+		if result.Time.After(now) {
+			now = result.Time
+		}
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+	w.expire(ctx, now)
+	return w.snapshot(now)
+}
+
+// apply diffs the result of the server with the tracked operations.
+func (w *Watcher) apply(server string, result Result) {
+	now := result.Time
+	seen := make(map[opKey]bool)
+	targets := make([]SPTaget, 0, len(result.Progress))
+	for target := range result.Progress {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+
+	for _, target := range targets {
+		for _, v := range result.Progress[target] {
+			key := keyOf(server, v)
+			seen[key] = true
+			op := w.running[key]
+			if op != nil && !sameRelation(op.Progress, v) {
+				// The backend moved on to another relation (e.g. autovacuum worker).
+				w.finish(op, now)
+				op = nil
+			}
+			if op == nil {
+				op = w.start(server, target, v, now)
+			} else {
+				w.progress(op, v, now)
+			}
+			op.Progress = v
+			op.LastSeen = now
+			op.est.Update(now, v.Phase(), v.Counter())
+		}
+	}
+
+	for key, op := range w.running {
+		if _, polled := result.Progress[op.Target]; polled && op.Server == server && !seen[key] {
+			w.finish(op, now)
+		}
+	}
+}
+
+// sameRelation returns true if a and b are of the same relation.
+// The OIDs are compared because the name may be resolved in only one of them.
+func sameRelation(a, b Progress) bool {
+	ra, ok := a.(Resolvable)
+	rb, ok2 := b.(Resolvable)
+	if !ok || !ok2 {
+		return a.Relation() == b.Relation()
+	}
+	datidA, relidA := ra.RelationID()
+	datidB, relidB := rb.RelationID()
+	return datidA == datidB && relidA == relidB
+}
+
+// expire determines the outcome of the finished operations
+// and drops the operations finished before Retention.
+func (w *Watcher) expire(ctx context.Context, now time.Time) {
+	ops := w.ops[:0]
+	for _, op := range w.ops {
+		if !op.Running() {
+			if c := w.collector(op.Server); op.Outcome == OutcomeUnknown && c != nil {
+				op.Outcome = c.Outcome(ctx, op.Progress, op.Finished)
+			}
+			expired := now.Sub(op.Finished) >= w.Retention
+			if !op.reported && (op.Outcome != OutcomeUnknown || expired) {
+				op.reported = true
+				w.emit(&OperationFinished{
+					EventInfo: EventInfo{Operation: op.Operation, Time: now},
+					Duration:  op.Duration(now),
+					Outcome:   op.Outcome,
+				})
+				if f, ok := w.collector(op.Server).(Forgetter); ok {
+					f.Forget(op.Progress)
+				}
+			}
+			if expired {
+				continue
+			}
+		}
+		ops = append(ops, op)
+	}
+	w.ops = ops
+}
+
+// progress emits the events of the change from the previous row of the operation.
+func (w *Watcher) progress(op *operation, v Progress, now time.Time) {
+	prev := op.Progress
+	info := EventInfo{Operation: op.Operation, Time: now}
+	info.Operation.Progress = v
+
+	if prev.Phase() != v.Phase() {
+		w.emit(&PhaseChanged{EventInfo: info, From: prev.Phase(), To: v.Phase()})
+	}
+	c := v.Counter()
+	if prev.Phase() != v.Phase() || prev.Counter() != c {
+		op.lastChange = now
+		op.stalled = false
+	} else if counted(c) && !op.stalled && now.Sub(op.lastChange) >= StallTimeout {
+		op.stalled = true
+		w.emit(&Stalled{EventInfo: info, Since: op.lastChange})
+	}
+
+	if ProgressStep > 0 {
+		st := v.Status()
+		step := int(st.Overall / ProgressStep)
+		if step > op.step {
+			op.step = step
+			w.emit(&OperationProgressed{EventInfo: info, Progress: st.Overall})
+		}
+	}
+}
+
+// counted returns true if the phase reports the counter by which a stall can be told
+// from slow work (e.g. CREATE INDEX sorting and "computing statistics" report none).
+func counted(c Counter) bool {
+	return c.Unit != "" && (c.Done != 0 || c.Total > 0)
+}
+
+func (w *Watcher) emit(e Event) {
+	w.events = append(w.events, e)
+}
+
+// collector returns the collector of the server.
+func (w *Watcher) collector(server string) Collector {
+	for _, s := range w.Servers {
+		if s.Name == server {
+			return s.Collector
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) start(server string, target SPTaget, v Progress, now time.Time) *operation {
+	w.lastID++
+	op := &operation{
+		Operation: Operation{
+			ID:       w.lastID,
+			Server:   server,
+			Target:   target,
+			Progress: v,
+			Started:  now,
+		},
+	}
+	op.lastChange = now
+	if ProgressStep > 0 {
+		op.step = int(v.Progress() / ProgressStep)
+	}
+	w.ops = append(w.ops, op)
+	w.running[keyOf(server, v)] = op
+	w.emit(&OperationStarted{EventInfo: EventInfo{Operation: op.Operation, Time: now}})
+	return op
+}
+
+func (w *Watcher) finish(op *operation, now time.Time) {
+	delete(w.running, keyOf(op.Server, op.Progress))
+	op.Finished = now
+}
+
+// snapshot returns the snapshot of the tracked operations.
+func (w *Watcher) snapshot(now time.Time) Snapshot {
+	s := Snapshot{
+		Time:       now,
+		Operations: make([]Operation, 0, len(w.ops)),
+		Servers:    make([]ServerState, len(w.Servers)),
+		Events:     w.events,
+	}
+	w.events = nil
+	var targets, status []string
+	for i, srv := range w.Servers {
+		st := ServerState{
+			Name:    srv.Name,
+			Targets: srv.Collector.TargetString(),
+			Status:  srv.Collector.TargetStatus(now),
+		}
+		if i < len(w.lost) {
+			st.Err, st.Attempt = w.lost[i].Err, w.lost[i].Attempt
+		}
+		s.Servers[i] = st
+		targets = append(targets, Prefixed(srv.Name, st.Targets))
+		if st.Status != "" {
+			status = append(status, Prefixed(srv.Name, st.Status))
+		}
+	}
+	s.Targets = strings.Join(targets, " ")
+	s.Status = strings.Join(status, "\n")
+	for _, op := range w.ops {
+		o := op.Operation
+		c := o.Progress.Counter()
+		o.Rate, o.HasRate = op.est.Rate()
+		o.PhaseETA, o.HasPhaseETA = op.est.PhaseETA(c)
+		s.Operations = append(s.Operations, o)
+	}
+	return s
+}
+
+// Prefixed returns s with the name of the server if it is named.
+func Prefixed(server string, s string) string {
+	if server == "" {
+		return s
+	}
+	return server + ": " + s
+}
@@ -0,0 +1,259 @@
+package pgsp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeCollector struct {
+	results   []Result
+	outcome   Outcome
+	outcomes  int
+	forgotten []Progress
+}
+
+func (f *fakeCollector) Poll(ctx context.Context) Result {
+	r := f.results[0]
+	f.results = f.results[1:]
+	return r
+}
+
+func (f *fakeCollector) Outcome(ctx context.Context, v Progress, vanished time.Time) Outcome {
+	f.outcomes++
+	return f.outcome
+}
+
+func (f *fakeCollector) Forget(v Progress) {
+	f.forgotten = append(f.forgotten, v)
+}
+
+func (f *fakeCollector) TargetString() string              { return "Vacuum" }
+func (f *fakeCollector) TargetStatus(now time.Time) string { return "" }
+
+func TestWatcher_Poll(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	vacuum := func(pid int, relid int, rel string, scanned int64) Vacuum {
+		return Vacuum{PID: pid, RELID: relid, RelName: rel, PHASE: "scanning heap", HeapBLKSTotal: 100, HeapBLKSScanned: scanned}
+	}
+	result := func(sec int, vs ...Progress) Result {
+		return Result{
+			Time:     start.Add(time.Duration(sec) * time.Second),
+			Progress: map[SPTaget][]Progress{SPVacuum: vs},
+		}
+	}
+	f := &fakeCollector{
+		outcome: OutcomeCompleted,
+		results: []Result{
+			result(0, vacuum(1, 1, "public.a", 0)),
+			result(1, vacuum(1, 1, "public.a", 10)),
+			result(2, vacuum(1, 1, "public.a", 20)),
+			// Moved on to another relation.
+			result(3, vacuum(1, 2, "public.b", 0)),
+			// Disappeared.
+			result(4),
+			// Not polled (failed): not considered as disappeared.
+			{Time: start.Add(20 * time.Second), Progress: map[SPTaget][]Progress{}},
+		},
+	}
+	w := NewWatcher(f, time.Second)
+	w.Retention = 10 * time.Second
+	ctx := context.Background()
+
+	s := w.Poll(ctx)
+	if len(s.Operations) != 1 || !s.Operations[0].Running() {
+		t.Fatalf("started: %+v", s.Operations)
+	}
+	w.Poll(ctx)
+	s = w.Poll(ctx)
+	op := s.Operations[0]
+	if !op.HasRate || op.Rate != 10 || !op.HasPhaseETA || op.PhaseETA != 8*time.Second {
+		t.Errorf("rate = %v %v, ETA = %v %v", op.Rate, op.HasRate, op.PhaseETA, op.HasPhaseETA)
+	}
+
+	s = w.Poll(ctx)
+	if len(s.Operations) != 2 {
+		t.Fatalf("relation changed: %+v", s.Operations)
+	}
+	if a := s.Operations[0]; a.Running() || a.Outcome != OutcomeCompleted || a.Duration(s.Time) != 3*time.Second {
+		t.Errorf("previous operation: %+v", a)
+	}
+	if b := s.Operations[1]; !b.Running() || b.ID == s.Operations[0].ID {
+		t.Errorf("next operation: %+v", b)
+	}
+
+	s = w.Poll(ctx)
+	if len(s.Operations) != 2 || s.Operations[1].Running() {
+		t.Fatalf("disappeared: %+v", s.Operations)
+	}
+	outcomes := f.outcomes
+
+	// Finished operations are dropped after the retention.
+	s = w.Poll(ctx)
+	if len(s.Operations) != 0 {
+		t.Errorf("retention: %+v", s.Operations)
+	}
+	if f.outcomes != outcomes {
+		t.Errorf("outcome determined again: %d", f.outcomes)
+	}
+}
+
+func TestWatcher_PollResolvedLate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := func(sec int, rel string) Result {
+		v := Vacuum{PID: 1, DATID: 5, RELID: 16384, RelName: rel, PHASE: "scanning heap", HeapBLKSTotal: 100, HeapBLKSScanned: int64(sec)}
+		return Result{
+			Time:     start.Add(time.Duration(sec) * time.Second),
+			Progress: map[SPTaget][]Progress{SPVacuum: {v}},
+		}
+	}
+	f := &fakeCollector{
+		outcome: OutcomeCancelled,
+		results: []Result{
+			// The name could not be resolved in the first poll.
+			result(0, ""),
+			result(1, "public.t"),
+		},
+	}
+	w := NewWatcher(f, time.Second)
+	ctx := context.Background()
+	w.Poll(ctx)
+	s := w.Poll(ctx)
+	if len(s.Operations) != 1 {
+		t.Fatalf("resolved late: %+v", s.Operations)
+	}
+	op := s.Operations[0]
+	if !op.Running() || op.ID != 1 || op.Progress.Relation() != "public.t" {
+		t.Errorf("resolved late: %+v", op)
+	}
+	for _, e := range s.Events {
+		t.Errorf("resolved late: event %v", e)
+	}
+}
+
+func TestWatcher_Events(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	index := func(sec int, phase string, done int64) Result {
+		return Result{
+			Time: start.Add(time.Duration(sec) * time.Second),
+			Progress: map[SPTaget][]Progress{
+				SPCreateIndex: {CreateIndex{PID: 1, Command: "CREATE INDEX", PHASE: phase, BlocksTotal: 100, BlocksDone: done}},
+			},
+		}
+	}
+	f := &fakeCollector{
+		outcome: OutcomeCompleted,
+		results: []Result{
+			index(0, "building index: scanning table", 0),
+			index(1, "building index: scanning table", 50),
+			index(100, "building index: scanning table", 50),
+			index(101, "building index: scanning table", 100),
+			index(102, "building index: sorting live tuples", 100),
+			// Sorting reports no counter and is not stalled.
+			index(200, "building index: sorting live tuples", 100),
+			{Time: start.Add(201 * time.Second), Progress: map[SPTaget][]Progress{SPCreateIndex: nil}},
+		},
+	}
+	w := NewWatcher(f, time.Second)
+	ctx := context.Background()
+	var got []string
+	for range f.results {
+		s := w.Poll(ctx)
+		for _, e := range s.Events {
+			switch e := e.(type) {
+			case *OperationStarted:
+				got = append(got, "started")
+			case *PhaseChanged:
+				got = append(got, "phase:"+e.To)
+			case *OperationProgressed:
+				got = append(got, "progressed")
+			case *Stalled:
+				got = append(got, "stalled")
+			case *OperationFinished:
+				if e.Duration != 201*time.Second || e.Outcome != OutcomeCompleted {
+					t.Errorf("OperationFinished = %v", e)
+				}
+				got = append(got, "finished")
+			}
+		}
+	}
+	want := []string{"started", "progressed", "stalled", "progressed", "phase:building index: sorting live tuples", "finished"}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("events = %v, want %v", got, want)
+			break
+		}
+	}
+	if len(f.forgotten) != 1 || f.forgotten[0].Pid() != 1 {
+		t.Errorf("forgotten = %v, want the finished operation", f.forgotten)
+	}
+}
+
+func TestWatcher_Servers(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := func(sec int, vs ...Progress) Result {
+		return Result{
+			Time:     start.Add(time.Duration(sec) * time.Second),
+			Progress: map[SPTaget][]Progress{SPVacuum: vs},
+		}
+	}
+	lost := errors.New("connection refused")
+	primary := &fakeCollector{
+		results: []Result{
+			result(0, Vacuum{PID: 1}),
+			result(1, Vacuum{PID: 1}),
+		},
+	}
+	replica := &fakeCollector{
+		results: []Result{
+			result(0, Vacuum{PID: 1}),
+			{Time: start.Add(time.Second), Err: lost},
+		},
+	}
+	w := NewServersWatcher([]Server{{Name: "primary", Collector: primary}, {Name: "replica", Collector: replica}}, time.Second)
+	ctx := context.Background()
+
+	s := w.Poll(ctx)
+	if len(s.Operations) != 2 || s.Operations[0].Server == s.Operations[1].Server {
+		t.Fatalf("same pid on two servers: %+v", s.Operations)
+	}
+	if s.Targets != "primary: Vacuum replica: Vacuum" {
+		t.Errorf("Targets = %q", s.Targets)
+	}
+
+	s = w.Poll(ctx)
+	for _, op := range s.Operations {
+		if !op.Running() {
+			t.Errorf("operation of %s finished: %+v", op.Server, op)
+		}
+	}
+	if len(s.Servers) != 2 || s.Servers[0].Err != nil || s.Servers[1].Err != lost {
+		t.Errorf("Servers = %+v", s.Servers)
+	}
+}
+
+func Test_counted(t *testing.T) {
+	tests := []struct {
+		name string
+		v    Progress
+		want bool
+	}{
+		{name: "scanning", v: Vacuum{PHASE: "scanning heap", HeapBLKSTotal: 100}, want: true},
+		{name: "indexesBefore17", v: Vacuum{PHASE: "vacuuming indexes"}, want: false},
+		{name: "indexes17", v: Vacuum{PHASE: "vacuuming indexes", IndexesTotal: 2}, want: true},
+		{name: "sorting", v: CreateIndex{PHASE: "building index: sorting live tuples", BlocksTotal: 100}, want: false},
+		{name: "computingStatistics", v: Analyze{PHASE: "computing statistics"}, want: false},
+		{name: "copyStdin", v: Copy{COMMAND: "COPY FROM", BYTESProcessed: 100}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := counted(tt.v.Counter()); got != tt.want {
+				t.Errorf("counted(%v) = %v, want %v", tt.v.Counter(), got, tt.want)
+			}
+		})
+	}
+}